@@ -1 +1,304 @@
+// Package MultiCameraLocal runs the local SSD face detector over several
+// capture devices or files at once. Every camera has its own reader; the
+// frames are detected by a bounded pool of workers sharing a small number of
+// gocv.Net instances, optionally batching the frames of several workers into
+// one forward pass, and each camera gets its own directory of annotated
+// images and a statistics file.
 package MultiCameraLocal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/capture"
+	"github.com/kkxu52452/videoCapAndProccess/config"
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"github.com/kkxu52452/videoCapAndProccess/output"
+	"gocv.io/x/gocv"
+)
+
+// Config describes a multi camera run.
+type Config struct {
+	Sources []string // capture.Open specs, one per camera
+
+	Model     string // network weights
+	NetConfig string // network description, empty for the manifest's
+	Manifest  string // model manifest, empty to look next to Model
+	Post      facedetect.PostProcess
+	Tiling    facedetect.Tiling
+	Batch     facedetect.Batching // with Size > 1 each net batches the frames of several workers
+	Backend   gocv.NetBackendType
+	Target    gocv.NetTargetType
+
+	Nets    int    // gocv.Net instances shared by the workers, at least 1
+	Workers int    // concurrent detections, at least 1
+	Frames  int    // frames per camera, 0 to read until the source ends
+	Out     string // output root, camera i writes to Out/cam<i>
+	JSONL   bool   // also write Out/cam<i>/detections.jsonl
+}
+
+// Stats are the per camera counters of a run.
+type Stats struct {
+	Camera   string        `json:"camera"`
+	Source   string        `json:"source"`
+	Frames   int           `json:"frames"`  // frames detected
+	Dropped  int           `json:"dropped"` // live frames skipped because all workers were busy
+	Errors   int           `json:"errors"`
+	Faces    int           `json:"faces"`
+	Detect   time.Duration `json:"detect_ns"` // summed detection time
+	Duration time.Duration `json:"duration_ns"`
+}
+
+func (s *Stats) String() string {
+	var avg time.Duration
+	if s.Frames > 0 {
+		avg = s.Detect / time.Duration(s.Frames)
+	}
+	fps := 0.0
+	if s.Duration > 0 {
+		fps = float64(s.Frames) / s.Duration.Seconds()
+	}
+	return fmt.Sprintf("%s (%s): %d frames, %d dropped, %d errors, %d faces, avg detect %s, %.2f fps",
+		s.Camera, s.Source, s.Frames, s.Dropped, s.Errors, s.Faces, avg, fps)
+}
+
+// job is one frame waiting for detection.
+type job struct {
+	cam   *camera
+	index int
+	img   gocv.Mat
+	time  time.Time // capture time
+}
+
+// camera is an open source and its output.
+type camera struct {
+	src     capture.FrameSource
+	dir     string
+	records *output.JSONL // nil unless Config.JSONL is set
+	backend string        // Face.Source of the loaded model, for the records
+	mu      sync.Mutex
+	stats   Stats
+}
+
+// color for the rect when faces detected
+var green = color.RGBA{0, 255, 0, 0}
+
+// Run opens every source and detects faces until each camera has delivered
+// cfg.Frames frames, its source ends, or ctx is cancelled. It returns the
+// statistics of each camera in the order of cfg.Sources.
+func Run(ctx context.Context, cfg Config) ([]Stats, error) {
+	if len(cfg.Sources) == 0 {
+		return nil, fmt.Errorf("no sources given")
+	}
+	if cfg.Nets < 1 {
+		cfg.Nets = 1
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+
+	// load the nets shared by the workers
+	manifest, err := facedetect.ResolveManifest(cfg.Model, cfg.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	// a worker takes a net for each frame; a batching net is lent to
+	// several workers at once, so that their frames can share a pass
+	lend := 1
+	if cfg.Batch.Size > 1 {
+		lend = (cfg.Workers + cfg.Nets - 1) / cfg.Nets
+	}
+	var closers []io.Closer
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+	nets := make(chan facedetect.Detector, cfg.Nets*lend)
+	var backend string
+	for i := 0; i < cfg.Nets; i++ {
+		l, err := facedetect.NewLocal(cfg.Model, cfg.NetConfig, manifest, cfg.Backend, cfg.Target)
+		if err != nil {
+			return nil, err
+		}
+		backend = l.Source()
+		l.Post, l.Tiling = cfg.Post, cfg.Tiling
+		var net facedetect.Detector = l
+		closers = append(closers, l)
+		if cfg.Batch.Size > 1 {
+			b := facedetect.NewBatcher(l, cfg.Batch)
+			net, closers[i] = b, b
+		}
+		for k := 0; k < lend; k++ {
+			nets <- net
+		}
+	}
+
+	cams := make([]*camera, 0, len(cfg.Sources))
+	defer func() {
+		for _, c := range cams {
+			c.src.Close()
+			if c.records != nil {
+				c.records.Close()
+			}
+		}
+	}()
+	for i, spec := range cfg.Sources {
+		// stats and records name the source without its credentials
+		name := config.RedactURL(spec)
+		src, err := capture.Open(spec)
+		if err != nil {
+			return nil, config.RedactError(strings.NewReplacer(spec, name), err)
+		}
+		c := &camera{src: src, dir: filepath.Join(cfg.Out, fmt.Sprintf("cam%d", i)), backend: backend}
+		c.stats.Camera = fmt.Sprintf("cam%d", i)
+		c.stats.Source = name
+		cams = append(cams, c)
+		if err := os.MkdirAll(c.dir, 0755); err != nil {
+			return nil, err
+		}
+		if cfg.JSONL {
+			if c.records, err = output.CreateJSONL(filepath.Join(c.dir, "detections.jsonl")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	jobs := make(chan job, cfg.Workers)
+	var workers sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					// shutting down, drain the queue without detecting
+					j.img.Close()
+					continue
+				}
+				net := <-nets
+				process(ctx, net, j)
+				nets <- net
+			}
+		}()
+	}
+
+	var readers sync.WaitGroup
+	for _, c := range cams {
+		readers.Add(1)
+		go func(c *camera) {
+			defer readers.Done()
+			start := time.Now()
+			read(ctx, c, cfg.Frames, jobs)
+			c.mu.Lock()
+			c.stats.Duration = time.Since(start)
+			c.mu.Unlock()
+		}(c)
+	}
+	readers.Wait()
+	close(jobs)
+	workers.Wait()
+
+	stats := make([]Stats, len(cams))
+	for i, c := range cams {
+		stats[i] = c.stats
+		if err := writeStats(filepath.Join(c.dir, "stats.json"), &c.stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// read feeds frames of c into jobs. Frames of a live source are dropped when
+// every worker is busy; recorded sources wait for a free worker.
+func read(ctx context.Context, c *camera, frames int, jobs chan<- job) {
+	live := capture.IsLive(c.src)
+	for i := 0; frames == 0 || i < frames; i++ {
+		if ctx.Err() != nil {
+			return
+		}
+
+		img := gocv.NewMat()
+		if err := capture.ReadContext(ctx, c.src, &img); err != nil {
+			img.Close()
+			if err != io.EOF && err != ctx.Err() {
+				fmt.Printf("[ERR] %s: %v\n", c.stats.Camera, err)
+			}
+			return
+		}
+
+		j := job{cam: c, index: i, img: img, time: time.Now()}
+		if !live {
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				img.Close()
+				return
+			}
+			continue
+		}
+		select {
+		case jobs <- j:
+		default:
+			img.Close()
+			c.mu.Lock()
+			c.stats.Dropped++
+			c.mu.Unlock()
+		}
+	}
+}
+
+// process detects faces in one frame and saves it annotated.
+func process(ctx context.Context, net facedetect.Detector, j job) {
+	defer j.img.Close()
+
+	start := time.Now()
+	faces, err := net.Detect(ctx, j.img)
+	elapsed := time.Since(start)
+	if ctx.Err() != nil {
+		return
+	}
+
+	c := j.cam
+	c.mu.Lock()
+	c.stats.Detect += elapsed
+	if err != nil {
+		c.stats.Errors++
+	} else {
+		c.stats.Frames++
+		c.stats.Faces += len(faces)
+	}
+	c.mu.Unlock()
+	if c.records != nil {
+		r := output.NewRecord(j.index, j.time, c.stats.Source, c.backend, elapsed, faces, err)
+		if werr := c.records.Write(r); werr != nil {
+			fmt.Printf("[ERR] %s records: %v\n", c.stats.Camera, werr)
+		}
+	}
+	if err != nil {
+		fmt.Printf("[ERR] %s frame %d: %v\n", c.stats.Camera, j.index, err)
+		return
+	}
+
+	facedetect.DrawFaces(&j.img, faces, green, 2)
+	imgText := fmt.Sprintf("%s: found %d face in the Image; Time Consumed: %s", c.stats.Camera, len(faces), elapsed)
+	gocv.PutText(&j.img, imgText, image.Point{50, 50}, gocv.FontHersheyPlain, 1.8, green, 2)
+	gocv.IMWrite(filepath.Join(c.dir, fmt.Sprintf("%d.jpg", j.index)), j.img)
+}
+
+func writeStats(path string, s *Stats) error {
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(buf, '\n'), 0644)
+}
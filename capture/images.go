@@ -0,0 +1,76 @@
+package capture
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// imageExts are the still image formats picked up from a directory.
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".bmp":  true,
+}
+
+// imageSource reads still images one per frame.
+type imageSource struct {
+	name  string
+	files []string
+	next  int
+}
+
+// OpenImages reads the images matched by pattern in lexical order. pattern is
+// either a directory, from which all jpg, png and bmp files are taken, or a
+// filepath.Match glob.
+func OpenImages(pattern string) (FrameSource, error) {
+	var files []string
+	if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && imageExts[strings.ToLower(filepath.Ext(e.Name()))] {
+				files = append(files, filepath.Join(pattern, e.Name()))
+			}
+		}
+	} else {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad image pattern %q: %v", pattern, err)
+		}
+		files = matches
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no images found in %v", pattern)
+	}
+	sort.Strings(files)
+	return &imageSource{name: pattern, files: files}, nil
+}
+
+func (s *imageSource) Read(dst *gocv.Mat) error {
+	if s.next >= len(s.files) {
+		return io.EOF
+	}
+	file := s.files[s.next]
+	s.next++
+
+	img := gocv.IMRead(file, gocv.IMReadColor)
+	defer img.Close()
+	if img.Empty() {
+		return fmt.Errorf("cannot read image %v", file)
+	}
+	img.CopyTo(dst)
+	return nil
+}
+
+func (s *imageSource) Close() error   { return nil }
+func (s *imageSource) String() string { return s.name }
@@ -0,0 +1,154 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrClosed is returned by Latest.Wait once the buffer is closed and holds no
+// frame newer than the one asked for.
+var ErrClosed = errors.New("capture: frame buffer closed")
+
+// Frame describes a frame published in a Latest buffer.
+type Frame struct {
+	Seq  uint64    // position in the stream, the first frame is 1
+	Time time.Time // when the frame was read from the source
+}
+
+// Latest keeps the most recent frame of a live source so that a slow
+// consumer always works on a fresh image instead of a queue of stale ones.
+//
+// There is a single producer, either Grab or the caller of Put. It decodes
+// into a back buffer without holding the lock and publishes the frame by
+// swapping it with the front buffer, so readers never see a half written
+// Mat. Any number of consumers copy the front buffer out with Wait; comparing
+// sequence numbers tells them how many frames they skipped.
+type Latest struct {
+	mu     sync.Mutex
+	front  gocv.Mat
+	back   gocv.Mat // only touched by the producer
+	frame  Frame
+	closed bool
+	err    error
+	notify chan struct{} // closed on every publish and on Close
+}
+
+// NewLatest returns an empty buffer. Call Release once the producer and all
+// consumers are done with it.
+func NewLatest() *Latest {
+	return &Latest{
+		front:  gocv.NewMat(),
+		back:   gocv.NewMat(),
+		notify: make(chan struct{}),
+	}
+}
+
+// Grab reads src into the buffer until reading fails or ctx is cancelled,
+// then closes the buffer with that error and returns it. src is not closed.
+func (l *Latest) Grab(ctx context.Context, src FrameSource) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			l.CloseWithError(err)
+			return err
+		}
+		if err := ReadContext(ctx, src, &l.back); err != nil {
+			l.CloseWithError(err)
+			return err
+		}
+		l.publish(time.Now())
+	}
+}
+
+// Put publishes a copy of img captured at t. It must not be mixed with Grab
+// or called from several goroutines.
+func (l *Latest) Put(img gocv.Mat, t time.Time) {
+	img.CopyTo(&l.back)
+	l.publish(t)
+}
+
+func (l *Latest) publish(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.front, l.back = l.back, l.front
+	l.frame = Frame{Seq: l.frame.Seq + 1, Time: t}
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// Wait blocks until a frame newer than after is published and copies it into
+// dst. Pass 0 to get whatever frame is current, or the Seq of the previously
+// returned frame to wait for the next one. It returns ErrClosed once the
+// buffer is closed, or ctx.Err() when ctx is done first.
+func (l *Latest) Wait(ctx context.Context, after uint64, dst *gocv.Mat) (Frame, error) {
+	for {
+		l.mu.Lock()
+		if l.frame.Seq > after {
+			l.front.CopyTo(dst)
+			f := l.frame
+			l.mu.Unlock()
+			return f, nil
+		}
+		if l.closed {
+			l.mu.Unlock()
+			return Frame{}, ErrClosed
+		}
+		ch := l.notify
+		l.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return Frame{}, ctx.Err()
+		}
+	}
+}
+
+// Last returns the most recently published frame without copying it.
+func (l *Latest) Last() Frame {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.frame
+}
+
+// Close closes the buffer. Waiting consumers receive the remaining frame, if
+// they have not seen it yet, and ErrClosed after that.
+func (l *Latest) Close() error {
+	l.CloseWithError(nil)
+	return nil
+}
+
+// CloseWithError closes the buffer and records err as the reason, which Err
+// reports. Only the first call has an effect.
+func (l *Latest) CloseWithError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	l.err = err
+	close(l.notify)
+}
+
+// Err returns the reason the buffer was closed, nil while it is open or
+// when it was closed with Close.
+func (l *Latest) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Release frees both Mats. The producer must have stopped and no consumer
+// may call Wait afterwards.
+func (l *Latest) Release() {
+	l.Close()
+	l.front.Close()
+	l.back.Close()
+}
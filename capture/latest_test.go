@@ -0,0 +1,240 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// fakeSource delivers the values sent on frames as 1x1 frames and io.EOF
+// once frames is closed.
+type fakeSource struct {
+	frames chan uint8
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{frames: make(chan uint8)}
+}
+
+func (s *fakeSource) Read(dst *gocv.Mat) error {
+	return s.ReadContext(context.Background(), dst)
+}
+
+func (s *fakeSource) ReadContext(ctx context.Context, dst *gocv.Mat) error {
+	select {
+	case v, ok := <-s.frames:
+		if !ok {
+			return io.EOF
+		}
+		frame := gocv.NewMatWithSize(1, 1, gocv.MatTypeCV8U)
+		frame.SetUCharAt(0, 0, v)
+		frame.CopyTo(dst)
+		frame.Close()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *fakeSource) Close() error { return nil }
+
+// waitFor fails the test unless Wait returns a frame after seq within a
+// second.
+func waitFor(t *testing.T, l *Latest, after uint64, dst *gocv.Mat) Frame {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	f, err := l.Wait(ctx, after, dst)
+	if err != nil {
+		t.Fatalf("Wait(%d): %v", after, err)
+	}
+	return f
+}
+
+func TestLatestWins(t *testing.T) {
+	l := NewLatest()
+	defer l.Release()
+
+	img := gocv.NewMatWithSize(1, 1, gocv.MatTypeCV8U)
+	defer img.Close()
+	for v := uint8(1); v <= 5; v++ {
+		img.SetUCharAt(0, 0, v)
+		l.Put(img, time.Now())
+	}
+
+	dst := gocv.NewMat()
+	defer dst.Close()
+	f := waitFor(t, l, 0, &dst)
+	if f.Seq != 5 || dst.GetUCharAt(0, 0) != 5 {
+		t.Fatalf("got frame %d with value %d, want the newest frame 5", f.Seq, dst.GetUCharAt(0, 0))
+	}
+
+	// a consumer that has seen frame 5 waits for the next one
+	got := make(chan Frame)
+	go func(seen uint64) {
+		f, err := l.Wait(context.Background(), seen, &dst)
+		if err != nil {
+			t.Errorf("Wait(%d): %v", seen, err)
+		}
+		got <- f
+	}(f.Seq)
+	select {
+	case f := <-got:
+		t.Fatalf("Wait returned frame %d before it was published", f.Seq)
+	case <-time.After(20 * time.Millisecond):
+	}
+	img.SetUCharAt(0, 0, 6)
+	l.Put(img, time.Now())
+	if f := <-got; f.Seq != 6 || dst.GetUCharAt(0, 0) != 6 {
+		t.Fatalf("got frame %d with value %d, want 6", f.Seq, dst.GetUCharAt(0, 0))
+	}
+}
+
+func TestLatestCloseUnblocksWait(t *testing.T) {
+	l := NewLatest()
+	defer l.Release()
+
+	errs := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() {
+			dst := gocv.NewMat()
+			defer dst.Close()
+			_, err := l.Wait(context.Background(), 0, &dst)
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	l.Close()
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			if err != ErrClosed {
+				t.Fatalf("Wait after Close: got %v, want ErrClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Wait still blocked after Close")
+		}
+	}
+}
+
+func TestLatestWaitContext(t *testing.T) {
+	l := NewLatest()
+	defer l.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	dst := gocv.NewMat()
+	defer dst.Close()
+	if _, err := l.Wait(ctx, 0, &dst); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestLatestGrabEOF(t *testing.T) {
+	l := NewLatest()
+	defer l.Release()
+	src := newFakeSource()
+
+	done := make(chan error)
+	go func() {
+		done <- l.Grab(context.Background(), src)
+	}()
+	for v := uint8(1); v <= 3; v++ {
+		src.frames <- v
+	}
+	close(src.frames)
+	if err := <-done; err != io.EOF {
+		t.Fatalf("Grab returned %v, want io.EOF", err)
+	}
+	if l.Err() != io.EOF {
+		t.Fatalf("Err() = %v, want io.EOF", l.Err())
+	}
+
+	// the last frame is still handed out once, then the buffer reports the end
+	dst := gocv.NewMat()
+	defer dst.Close()
+	f := waitFor(t, l, 0, &dst)
+	if f.Seq != 3 || dst.GetUCharAt(0, 0) != 3 {
+		t.Fatalf("got frame %d with value %d, want the last frame 3", f.Seq, dst.GetUCharAt(0, 0))
+	}
+	if _, err := l.Wait(context.Background(), f.Seq, &dst); err != ErrClosed {
+		t.Fatalf("Wait after the last frame: got %v, want ErrClosed", err)
+	}
+}
+
+func TestLatestGrabCancel(t *testing.T) {
+	l := NewLatest()
+	defer l.Release()
+	src := newFakeSource() // never delivers
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- l.Grab(ctx, src)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("Grab returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Grab still blocked in Read after cancel")
+	}
+}
+
+// TestLatestConcurrent runs one producer against several consumers; run it
+// with -race. Every frame carries its sequence number, so a consumer seeing
+// another value got a torn or stale copy.
+func TestLatestConcurrent(t *testing.T) {
+	l := NewLatest()
+	defer l.Release()
+	src := newFakeSource()
+
+	grabbed := make(chan error)
+	go func() {
+		grabbed <- l.Grab(context.Background(), src)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dst := gocv.NewMat()
+			defer dst.Close()
+			var seq uint64
+			for {
+				f, err := l.Wait(context.Background(), seq, &dst)
+				if err == ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Errorf("Wait: %v", err)
+					return
+				}
+				if f.Seq <= seq {
+					t.Errorf("frame %d after %d", f.Seq, seq)
+				}
+				if v := dst.GetUCharAt(0, 0); v != uint8(f.Seq) {
+					t.Errorf("frame %d holds value %d", f.Seq, v)
+				}
+				seq = f.Seq
+			}
+		}()
+	}
+
+	for i := 1; i <= 500; i++ {
+		src.frames <- uint8(i)
+	}
+	close(src.frames)
+	<-grabbed
+	wg.Wait()
+	if last := l.Last(); last.Seq != 500 {
+		t.Fatalf("last frame %d, want 500", last.Seq)
+	}
+}
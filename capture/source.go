@@ -0,0 +1,100 @@
+// Package capture provides the frame sources the capture tools read from:
+// local cameras, video files, directories of still images, network streams
+// and a synthetic generator for machines without a camera.
+package capture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// FrameSource produces frames one at a time.
+type FrameSource interface {
+	// Read decodes the next frame into dst. It returns io.EOF once the
+	// source is exhausted or the device was closed.
+	Read(dst *gocv.Mat) error
+	Close() error
+}
+
+// ReadContext reads the next frame of src into dst. Sources that may block
+// for long, such as a stream waiting to reconnect, give up with ctx.Err()
+// once ctx is done; the others are read as they are.
+func ReadContext(ctx context.Context, src FrameSource, dst *gocv.Mat) error {
+	if r, ok := src.(interface {
+		ReadContext(context.Context, *gocv.Mat) error
+	}); ok {
+		return r.ReadContext(ctx, dst)
+	}
+	return src.Read(dst)
+}
+
+// IsLive reports whether src produces frames in real time, so that a slow
+// consumer should skip frames rather than fall behind. Files, image
+// directories and the generator are not live: every frame can be processed.
+func IsLive(src FrameSource) bool {
+	l, ok := src.(interface{ Live() bool })
+	return ok && l.Live()
+}
+
+// Open picks a FrameSource for spec:
+//
+//	0, 1, ...                  capture device ID
+//	rtsp://, http://, https:// network stream
+//	synthetic[:WxH[:N]]        generated frames, N=0 for an endless stream
+//	a directory or a glob      still images in lexical order
+//	anything else              video file
+func Open(spec string) (FrameSource, error) {
+	switch {
+	case spec == "":
+		return nil, fmt.Errorf("empty source")
+	case isDeviceID(spec):
+		id, _ := strconv.Atoi(spec)
+		return OpenDevice(id)
+	case strings.HasPrefix(spec, "rtsp://"), strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return OpenStream(spec)
+	case spec == "synthetic" || strings.HasPrefix(spec, "synthetic:"):
+		return parseSynthetic(spec)
+	case strings.ContainsAny(spec, "*?["):
+		return OpenImages(spec)
+	}
+
+	if fi, err := os.Stat(spec); err == nil && fi.IsDir() {
+		return OpenImages(spec)
+	}
+	return OpenVideoFile(spec)
+}
+
+func isDeviceID(spec string) bool {
+	for _, r := range spec {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseSynthetic(spec string) (FrameSource, error) {
+	width, height, count := 640, 480, 0
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("bad synthetic source %q, want synthetic[:WxH[:N]]", spec)
+	}
+	if len(parts) > 1 {
+		if _, err := fmt.Sscanf(parts[1], "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("bad synthetic frame size %q, want WxH", parts[1])
+		}
+	}
+	if len(parts) > 2 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bad synthetic frame count %q", parts[2])
+		}
+		count = n
+	}
+	return NewSynthetic(width, height, count), nil
+}
@@ -0,0 +1,56 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"gocv.io/x/gocv"
+)
+
+// Synthetic generates frames with a bright disc moving across a gray
+// background and the frame number in the corner. The output only depends on
+// the frame number, so runs are reproducible.
+type Synthetic struct {
+	Width, Height int
+	Count         int // number of frames, 0 for an endless stream
+
+	next int
+}
+
+// NewSynthetic returns a generator of count frames of the given size.
+func NewSynthetic(width, height, count int) *Synthetic {
+	return &Synthetic{Width: width, Height: height, Count: count}
+}
+
+// Read implements FrameSource.
+func (s *Synthetic) Read(dst *gocv.Mat) error {
+	if s.Count > 0 && s.next >= s.Count {
+		return io.EOF
+	}
+	i := s.next
+	s.next++
+
+	frame := gocv.NewMatWithSizeFromScalar(gocv.NewScalar(64, 64, 64, 0), s.Height, s.Width, gocv.MatTypeCV8UC3)
+	defer frame.Close()
+
+	radius := s.Height / 6
+	span := s.Width - 2*radius
+	if span < 1 {
+		span = 1
+	}
+	center := image.Pt(radius+(i*8)%span, s.Height/2)
+	gocv.Circle(&frame, center, radius, color.RGBA{220, 200, 180, 0}, -1)
+	gocv.PutText(&frame, fmt.Sprintf("#%d", i), image.Pt(10, 30), gocv.FontHersheyPlain, 1.8, color.RGBA{255, 255, 255, 0}, 2)
+
+	frame.CopyTo(dst)
+	return nil
+}
+
+// Close implements FrameSource.
+func (s *Synthetic) Close() error { return nil }
+
+func (s *Synthetic) String() string {
+	return fmt.Sprintf("synthetic %dx%d", s.Width, s.Height)
+}
@@ -0,0 +1,130 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// videoSource reads from an OpenCV VideoCapture: a camera, a video file or a
+// network stream.
+type videoSource struct {
+	name string
+	cap  *gocv.VideoCapture // nil after a failed reconnect
+	live bool
+
+	// stream reconnection, zero for devices and files
+	reconnects int
+	backoff    time.Duration
+}
+
+// OpenDevice opens the local capture device with the given ID.
+func OpenDevice(id int) (FrameSource, error) {
+	webcam, err := gocv.OpenVideoCapture(id)
+	if err != nil {
+		return nil, fmt.Errorf("error opening video capture device: %v", id)
+	}
+	return &videoSource{name: fmt.Sprintf("device %d", id), cap: webcam, live: true}, nil
+}
+
+// OpenVideoFile opens a recorded video file.
+func OpenVideoFile(path string) (FrameSource, error) {
+	video, err := gocv.VideoCaptureFile(path)
+	if err != nil || !video.IsOpened() {
+		return nil, fmt.Errorf("error opening video file: %v", path)
+	}
+	return &videoSource{name: path, cap: video}, nil
+}
+
+// OpenStream opens an RTSP or HTTP stream. A stream that stops delivering
+// frames is reopened a few times before Read gives up with io.EOF.
+func OpenStream(url string) (FrameSource, error) {
+	stream, err := gocv.VideoCaptureFile(url)
+	if err != nil || !stream.IsOpened() {
+		return nil, fmt.Errorf("error opening stream: %v", url)
+	}
+	return &videoSource{name: url, cap: stream, live: true, reconnects: 3, backoff: time.Second}, nil
+}
+
+func (v *videoSource) Read(dst *gocv.Mat) error {
+	return v.ReadContext(context.Background(), dst)
+}
+
+// ReadContext is Read that stops waiting to reconnect a stream when ctx is
+// done.
+func (v *videoSource) ReadContext(ctx context.Context, dst *gocv.Mat) error {
+	if v.cap != nil {
+		if ok := v.cap.Read(dst); ok && !dst.Empty() {
+			return nil
+		}
+	}
+
+	for i := 0; i < v.reconnects; i++ {
+		t := time.NewTimer(v.backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		if v.cap != nil {
+			v.cap.Close()
+			v.cap = nil
+		}
+		stream, err := v.reopen(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		v.cap = stream
+		if ok := v.cap.Read(dst); ok && !dst.Empty() {
+			return nil
+		}
+	}
+	return io.EOF
+}
+
+// reopen opens the stream again. Opening cannot be interrupted and may hang
+// on an unreachable host, so it runs aside; when ctx is done first, the
+// capture is closed as soon as it opens.
+func (v *videoSource) reopen(ctx context.Context) (*gocv.VideoCapture, error) {
+	type opened struct {
+		cap *gocv.VideoCapture
+		err error
+	}
+	ch := make(chan opened, 1)
+	go func() {
+		stream, err := gocv.VideoCaptureFile(v.name)
+		if err == nil && !stream.IsOpened() {
+			stream.Close()
+			err = fmt.Errorf("error opening stream: %v", v.name)
+		}
+		ch <- opened{stream, err}
+	}()
+	select {
+	case o := <-ch:
+		return o.cap, o.err
+	case <-ctx.Done():
+		go func() {
+			if o := <-ch; o.err == nil {
+				o.cap.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (v *videoSource) Close() error {
+	if v.cap == nil {
+		return nil
+	}
+	return v.cap.Close()
+}
+
+func (v *videoSource) Live() bool     { return v.live }
+func (v *videoSource) String() string { return v.name }
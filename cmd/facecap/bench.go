@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/capture"
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"github.com/kkxu52452/videoCapAndProccess/pipeline"
+	"github.com/kkxu52452/videoCapAndProccess/transport"
+	"gocv.io/x/gocv"
+)
+
+func benchCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	source := fs.String("source", "0", sourceUsage)
+	frames := fs.Int("frames", 20, "number of measured frames")
+	warmup := fs.Int("warmup", 1, "frames detected before measuring")
+	depth := fs.Int("pipeline", 1, "detect requests kept in flight, compare e.g. 1 and 4 to see the effective frame rate against the latency")
+	var det detectorFlags
+	det.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *frames <= 0 {
+		return usageError("--frames must be positive, got %d", *frames)
+	}
+	if *warmup < 0 {
+		return usageError("--warmup must not be negative, got %d", *warmup)
+	}
+	if *depth < 1 {
+		return usageError("--pipeline must be at least 1, got %d", *depth)
+	}
+	if err := det.validate(); err != nil {
+		return err
+	}
+
+	detector, err := det.open()
+	if err != nil {
+		return err
+	}
+	defer closeDetector(detector)
+
+	src, _, err := openSource(*source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	img := gocv.NewMat()
+	defer img.Close()
+
+	for i := 0; i < *warmup; i++ {
+		if err := capture.ReadContext(ctx, src, &img); err != nil {
+			fmt.Printf("Source ended after %d frames: %v\n", i, err)
+			return nil
+		}
+		detector.Detect(ctx, img)
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted")
+			return nil
+		}
+	}
+
+	pipe := pipeline.New(ctx, detector, pipeline.Config{Depth: *depth})
+	go func() {
+		defer pipe.Close()
+		for i := *warmup; i < *warmup+*frames; i++ {
+			if err := pipe.Reserve(ctx); err != nil {
+				return
+			}
+			f := pipeline.Frame{Index: i, Mat: gocv.NewMat()}
+			if err := capture.ReadContext(ctx, src, &f.Mat); err != nil {
+				f.Mat.Close()
+				pipe.Release()
+				fmt.Printf("Source ended after %d frames: %v\n", i, err)
+				return
+			}
+			pipe.Submit(f)
+		}
+	}()
+
+	var latencies []time.Duration
+	var faces int
+	failed := make(map[string]int)
+	for r := range pipe.Results() {
+		r.Mat.Close()
+		if ctx.Err() != nil {
+			continue
+		}
+		if r.Err != nil {
+			failed[facedetect.ErrorKind(r.Err)]++
+			fmt.Printf("[ERR] frame %d: %v\n", r.Index, r.Err)
+			continue
+		}
+		faces += len(r.Faces)
+		latencies = append(latencies, r.Latency)
+	}
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted")
+	}
+
+	printLatencies(det.name, latencies, failed, faces)
+	fmt.Printf("pipeline: %v\n", pipe.Stats())
+	if det.resilient != nil {
+		fmt.Printf("remote calls: %v\n", det.resilient.Stats())
+	}
+	if det.limiter != nil {
+		fmt.Printf("rate limit: %v\n", det.limiter.Stats())
+	}
+	if det.batcher != nil {
+		fmt.Printf("batches: %v\n", det.batcher.Stats())
+	}
+	if stats, ok := transport.Stats(det.client); ok {
+		fmt.Printf("connections: %v\n", stats)
+	}
+	return nil
+}
+
+// printLatencies reports the distribution of the successful detect calls.
+func printLatencies(name string, latencies []time.Duration, failed map[string]int, faces int) {
+	errors := 0
+	for _, n := range failed {
+		errors += n
+	}
+	fmt.Printf("detector: %s, frames: %d, errors: %d, faces: %d\n", name, len(latencies), errors, faces)
+	if errors > 0 {
+		fmt.Printf("failures: %s\n", formatCounts(failed))
+	}
+	if len(latencies) == 0 {
+		return
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	pct := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	fmt.Printf("latency min %s, avg %s, p50 %s, p95 %s, max %s\n",
+		sorted[0], sum/time.Duration(len(sorted)), pct(0.5), pct(0.95), sorted[len(sorted)-1])
+	fmt.Printf("throughput: %.2f frames/s\n", float64(len(sorted))/sum.Seconds())
+}
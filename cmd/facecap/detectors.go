@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/config"
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"github.com/kkxu52452/videoCapAndProccess/transport"
+	"gocv.io/x/gocv"
+)
+
+var detectorNames = []string{
+	facedetect.SourceBaidu,
+	facedetect.SourceFDNBaidu,
+	facedetect.SourceZZ,
+	facedetect.SourceIBM,
+	facedetect.SourceCaffe,
+}
+
+// detectorFlags selects and configures a detection backend.
+type detectorFlags struct {
+	name     string
+	url      string
+	model    string
+	config   string
+	manifest string
+	backend  string
+	target   string
+	local    localFlags
+
+	configFile  string
+	secretsFile string
+	cfg         *config.Config // loaded by validate
+
+	resilience facedetect.ResilienceConfig
+	resilient  *facedetect.Resilient // set by open for remote detectors
+
+	qps     float64
+	burst   int
+	drop    bool
+	maxWait time.Duration
+	limiter *facedetect.RateLimited // set by open when a rate limit applies
+
+	batcher *facedetect.Batcher // set by open when the caffe detector batches
+
+	params facedetect.BaiduParams
+
+	proxy       string
+	noKeepAlive bool
+	client      *http.Client // set by open for remote detectors
+}
+
+func (d *detectorFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&d.name, "detector", facedetect.SourceCaffe, "detection backend: "+strings.Join(detectorNames, "|"))
+	fs.StringVar(&d.url, "url", "", "endpoint of a remote detector, overriding the configuration")
+	fs.StringVar(&d.model, "model", "", "model weights for the caffe detector, Caffe .caffemodel, TensorFlow .pb or .onnx, overriding local.model")
+	fs.StringVar(&d.config, "config", "", "network description for the caffe detector (default local.config, the manifest's or "+facedetect.DefaultManifest.Config+")")
+	fs.StringVar(&d.manifest, "manifest", "", "YAML or JSON manifest of the caffe detector's model, overriding local.manifest (default <model>.yaml)")
+	fs.StringVar(&d.backend, "backend", "", "OpenCV DNN backend for the caffe detector")
+	fs.StringVar(&d.target, "target", "", "OpenCV DNN target for the caffe detector")
+	d.local.register(fs)
+	fs.StringVar(&d.configFile, "config-file", os.Getenv("FACECAP_CONFIG_FILE"), "YAML file with the backend endpoints and credentials")
+	fs.StringVar(&d.secretsFile, "secrets-file", os.Getenv("FACECAP_SECRETS_FILE"), "YAML file with backend secrets, merged over --config-file")
+
+	r := &d.resilience
+	*r = facedetect.DefaultResilience
+	fs.DurationVar(&r.Timeout, "timeout", r.Timeout, "limit of each remote detector request, 0 for none")
+	fs.IntVar(&r.MaxRetries, "retries", r.MaxRetries, "retries of a failed remote request")
+	fs.DurationVar(&r.BaseBackoff, "backoff", r.BaseBackoff, "backoff before the first retry, doubled for each further one")
+	fs.DurationVar(&r.MaxBackoff, "max-backoff", r.MaxBackoff, "cap of the retry backoff")
+	fs.IntVar(&r.BreakerThreshold, "breaker-threshold", r.BreakerThreshold, "consecutive failures opening the circuit breaker, 0 to disable")
+	fs.DurationVar(&r.BreakerCooldown, "breaker-cooldown", r.BreakerCooldown, "time the circuit breaker stays open before a probe")
+
+	fs.Float64Var(&d.qps, "qps", 0, "requests per second sent to a remote detector, overriding rate_limit.qps (baidu defaults to 2)")
+	fs.IntVar(&d.burst, "burst", 0, "requests that may be sent back to back, overriding rate_limit.burst")
+	fs.BoolVar(&d.drop, "drop", false, "drop frames that would wait longer than --max-wait for the rate limit")
+	fs.DurationVar(&d.maxWait, "max-wait", 0, "longest a frame waits for the rate limit with --drop")
+
+	fs.StringVar(&d.params.FaceField, "face-field", "", "attributes Baidu returns besides the box, overriding params.face_field")
+	fs.IntVar(&d.params.MaxFaceNum, "max-face-num", 0, "faces Baidu returns at most, overriding params.max_face_num (Baidu's default is 1)")
+	fs.StringVar(&d.params.FaceType, "face-type", "", "Baidu face_type: LIVE, IDCARD, WATERMARK, CERT or INFRARED")
+	fs.StringVar(&d.params.LivenessControl, "liveness-control", "", "Baidu liveness_control: NONE, LOW, NORMAL or HIGH")
+
+	fs.StringVar(&d.proxy, "proxy", "", "proxy URL for a remote detector or \"direct\", overriding http.proxy (default from HTTPS_PROXY)")
+	fs.BoolVar(&d.noKeepAlive, "no-keepalive", false, "open a new connection for every remote request, to measure what reuse saves")
+}
+
+func (d *detectorFlags) validate() error {
+	known := false
+	for _, name := range detectorNames {
+		if name == d.name {
+			known = true
+		}
+	}
+	if !known {
+		return usageError("unknown detector %q, want one of %s", d.name, strings.Join(detectorNames, ", "))
+	}
+	if r := d.resilience; r.Timeout < 0 || r.MaxRetries < 0 || r.BaseBackoff < 0 || r.MaxBackoff < 0 ||
+		r.BreakerThreshold < 0 || r.BreakerCooldown < 0 {
+		return usageError("--timeout, --retries, --backoff, --max-backoff and --breaker-* must not be negative")
+	}
+	if d.qps < 0 || d.burst < 0 || d.maxWait < 0 || d.params.MaxFaceNum < 0 {
+		return usageError("--qps, --burst, --max-wait and --max-face-num must not be negative")
+	}
+
+	cfg, err := config.Load(d.configFile, d.secretsFile)
+	if err != nil {
+		return err
+	}
+	d.cfg = cfg
+	redactor = cfg.Redactor()
+
+	if d.name == facedetect.SourceCaffe {
+		if d.model == "" {
+			d.model = cfg.Local.Model
+		}
+		if d.model == "" {
+			return usageError("--model or local.model is required for the %s detector", d.name)
+		}
+		if d.local.p, err = d.local.mergePost(cfg.Local.PostProcess); err != nil {
+			return err
+		}
+		if d.local.tiling, err = d.local.mergeTiling(cfg.Local.Tiling); err != nil {
+			return err
+		}
+		d.local.batch, err = d.local.mergeBatch(cfg.Local.Batch)
+		return err
+	}
+
+	b := cfg.Backend(d.name)
+	if d.url != "" {
+		b.URL = d.url
+	}
+	switch {
+	case d.name == facedetect.SourceBaidu && (b.APIKey == "") != (b.SecretKey == ""):
+		return usageError("baidu api_key and secret_key must be configured together")
+	case d.name == facedetect.SourceBaidu && b.APIKey == "" && b.URL == "":
+		return usageError("configure baidu api_key and secret_key, or a url carrying an access_token")
+	case d.name != facedetect.SourceBaidu && b.URL == "":
+		return usageError("no URL configured for %s: set --url, backends.%s.url or %s", d.name, d.name, config.EnvVar(d.name, "URL"))
+	}
+	return nil
+}
+
+// defaultTokenCache returns the per-user location of the Baidu token cache.
+func defaultTokenCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "facecap", "baidu-token.json")
+}
+
+// open builds the selected detector from the configuration loaded by
+// validate. The returned Detector may implement io.Closer; use closeDetector
+// when done with it.
+func (d *detectorFlags) open() (facedetect.Detector, error) {
+	if d.name == facedetect.SourceCaffe {
+		return d.openLocal()
+	}
+
+	b := d.cfg.Backend(d.name)
+	if d.proxy != "" {
+		b.HTTP.Proxy = d.proxy
+	}
+	if d.noKeepAlive {
+		b.HTTP.DisableKeepAlives = true
+	}
+	params := d.baiduParams(b)
+	client, err := newHTTPClient(d.name, b)
+	if err != nil {
+		return nil, err
+	}
+	d.client = client
+	var det facedetect.Detector
+	switch d.name {
+	case facedetect.SourceBaidu:
+		if b.APIKey == "" {
+			baidu := facedetect.NewBaidu(b.URL)
+			baidu.Client, baidu.Params = client, params
+			det = baidu
+			break
+		}
+		cache := b.TokenCache
+		if cache == "" {
+			cache = defaultTokenCache()
+		}
+		baidu := facedetect.NewBaiduWithKeys(b.APIKey, b.SecretKey, cache)
+		if b.URL != "" {
+			baidu.URL = b.URL
+		}
+		if b.TokenURL != "" {
+			baidu.Token.TokenURL = b.TokenURL
+		}
+		baidu.Client, baidu.Token.Client = client, client
+		baidu.Token.Timeout = b.TokenTimeout
+		baidu.Params = params
+		det = baidu
+	case facedetect.SourceFDNBaidu:
+		fdn := facedetect.NewFDNBaidu(b.URL)
+		fdn.Client, fdn.Params = client, params
+		det = fdn
+	case facedetect.SourceZZ:
+		zz := facedetect.NewZZ(b.URL)
+		zz.Client = client
+		det = zz
+	case facedetect.SourceIBM:
+		ibm := facedetect.NewIBM(b.URL)
+		ibm.Username, ibm.Password = b.Username, b.Password
+		ibm.Client, ibm.Params = client, params
+		det = ibm
+	}
+	// the limiter goes inside Resilient so that retries are paced too;
+	// Resilient times an attempt only once the limiter let it through
+	if limit, ok := d.rateLimit(b); ok {
+		d.limiter = facedetect.NewRateLimited(det, limit)
+		det = d.limiter
+	}
+	d.resilient = facedetect.NewResilient(det, d.resilience)
+	return &redactingDetector{d.resilient}, nil
+}
+
+// baiduParams merges the configured Baidu parameters of b with the flags.
+func (d *detectorFlags) baiduParams(b *config.Backend) facedetect.BaiduParams {
+	p := b.Params
+	p.FaceField = firstNonEmpty(d.params.FaceField, p.FaceField)
+	p.FaceType = firstNonEmpty(d.params.FaceType, p.FaceType)
+	p.LivenessControl = firstNonEmpty(d.params.LivenessControl, p.LivenessControl)
+	if d.params.MaxFaceNum > 0 {
+		p.MaxFaceNum = d.params.MaxFaceNum
+	}
+	return p
+}
+
+// rateLimit merges the configured rate limit of b with the flags. Baidu gets
+// its free tier limit unless told otherwise.
+func (d *detectorFlags) rateLimit(b *config.Backend) (facedetect.RateLimitConfig, bool) {
+	var limit facedetect.RateLimitConfig
+	switch {
+	case b.RateLimit != nil:
+		limit = *b.RateLimit
+	case d.name == facedetect.SourceBaidu:
+		limit = facedetect.BaiduFreeTier
+	}
+	if d.qps > 0 {
+		limit.QPS = d.qps
+	}
+	if d.burst > 0 {
+		limit.Burst = d.burst
+	}
+	if d.drop {
+		limit.Drop = true
+	}
+	if d.maxWait > 0 {
+		limit.MaxWait = d.maxWait
+	}
+	return limit, limit.QPS > 0
+}
+
+// newHTTPClient returns the client all requests to the backend share,
+// honoring its TLS and connection settings.
+func newHTTPClient(name string, b *config.Backend) (*http.Client, error) {
+	var tlsConfig *tls.Config
+	if !b.TLS.IsZero() {
+		var err error
+		tlsConfig, err = b.TLS.Build(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := transport.NewClient(b.HTTP, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return client, nil
+}
+
+func (d *detectorFlags) openLocal() (facedetect.Detector, error) {
+	local := d.cfg.Local
+	manifest, err := facedetect.ResolveManifest(d.model, firstNonEmpty(d.manifest, local.Manifest))
+	if err != nil {
+		return nil, err
+	}
+	netConfig := firstNonEmpty(d.config, local.Config, manifest.Config)
+
+	backend := gocv.NetBackendDefault
+	if name := firstNonEmpty(d.backend, local.Backend); name != "" {
+		backend = gocv.ParseNetBackend(name)
+	}
+	target := gocv.NetTargetCPU
+	if name := firstNonEmpty(d.target, local.Target); name != "" {
+		target = gocv.ParseNetTarget(name)
+	}
+	l, err := facedetect.NewLocal(d.model, netConfig, manifest, backend, target)
+	if err != nil {
+		return nil, err
+	}
+	l.Post, l.Tiling = d.local.p, d.local.tiling
+	if d.local.batch.Size > 1 {
+		d.batcher = facedetect.NewBatcher(l, d.local.batch)
+		return d.batcher, nil
+	}
+	return l, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// redactor masks configured secrets in anything the commands print or save.
+var redactor = strings.NewReplacer()
+
+// redactingDetector masks secrets in the errors of a remote detector, since
+// they end up in logs, overlays and detection records.
+type redactingDetector struct {
+	facedetect.Detector
+}
+
+func (r *redactingDetector) Detect(ctx context.Context, img gocv.Mat) ([]facedetect.Face, error) {
+	faces, err := r.Detector.Detect(ctx, img)
+	return faces, config.RedactError(redactor, err)
+}
+
+func (r *redactingDetector) Close() error {
+	closeDetector(r.Detector)
+	return nil
+}
+
+// closeDetector releases det if it holds resources.
+func closeDetector(det facedetect.Detector) {
+	if c, ok := det.(io.Closer); ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/kkxu52452/videoCapAndProccess/capture"
+	"github.com/kkxu52452/videoCapAndProccess/config"
+	"gocv.io/x/gocv"
+)
+
+func inspectCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	source := fs.String("source", "0", sourceUsage)
+	detect := fs.Bool("detect", false, "run the detector on the first frame and list the faces")
+	var det detectorFlags
+	det.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *detect {
+		if err := det.validate(); err != nil {
+			return err
+		}
+	}
+
+	src, _, err := openSource(*source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	img := gocv.NewMat()
+	defer img.Close()
+	if err := capture.ReadContext(ctx, src, &img); err != nil {
+		return fmt.Errorf("cannot read a frame from %v: %v", src, err)
+	}
+
+	fmt.Printf("source:      %v\n", config.RedactURL(fmt.Sprint(src)))
+	fmt.Printf("live:        %v\n", capture.IsLive(src))
+	fmt.Printf("frame size:  %dx%d, %d channels\n", img.Cols(), img.Rows(), img.Channels())
+
+	if !*detect {
+		return nil
+	}
+
+	detector, err := det.open()
+	if err != nil {
+		return err
+	}
+	defer closeDetector(detector)
+
+	faces, err := detector.Detect(ctx, img)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("faces (%s): %d\n", det.name, len(faces))
+	for i, f := range faces {
+		fmt.Printf("  #%d box %v confidence %.3f rotation %.1f bounds %v\n", i, f.Box, f.Confidence, f.Rotation, f.Bounds())
+		if a := f.Attributes; a != nil {
+			fmt.Printf("     %v, %d landmarks\n", a, len(a.Landmarks))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+)
+
+// localFlags tunes the tiling and batching of the local detector and the
+// filtering of its raw detections.
+type localFlags struct {
+	p      facedetect.PostProcess
+	tiling facedetect.Tiling
+	batch  facedetect.Batching
+}
+
+func (f *localFlags) register(fs *flag.FlagSet) {
+	d := facedetect.DefaultPostProcess
+	fs.Float64Var(&f.p.Threshold, "threshold", 0, "minimum confidence of a local detection, negative to keep all, overriding local.postprocess (default "+fmt.Sprint(d.Threshold)+")")
+	fs.StringVar(&f.p.NMS, "nms", "", "suppression of overlapping local detections: iou, soft or none (default "+d.NMS+")")
+	fs.Float64Var(&f.p.IoU, "iou", 0, "overlap at which --nms iou drops the weaker face (default "+fmt.Sprint(d.IoU)+")")
+	fs.Float64Var(&f.p.SoftSigma, "soft-sigma", 0, "decay of --nms soft, smaller suppresses more (default "+fmt.Sprint(d.SoftSigma)+")")
+	fs.IntVar(&f.p.MaxFaces, "max-faces", 0, "strongest local detections kept per frame, 0 for all")
+	fs.IntVar(&f.p.MinFaceSize, "min-face-size", 0, "drop local detections with a side shorter than this many pixels")
+	fs.IntVar(&f.tiling.Size, "tile-size", 0, "also run the local detector over square tiles of this many pixels, finding smaller faces; 0 for one pass, overriding local.tiling.size")
+	fs.Float64Var(&f.tiling.Overlap, "tile-overlap", 0, "share of a tile covered by its neighbor with --tile-size, overriding local.tiling.overlap (default "+fmt.Sprint(defaultTileOverlap)+")")
+	fs.IntVar(&f.batch.Size, "batch-size", 0, "run up to this many images of frames in flight at once through one forward pass of the local detector, overriding local.batch.size")
+	fs.DurationVar(&f.batch.MaxWait, "batch-wait", 0, "longest a frame waits for its batch to fill with --batch-size, overriding local.batch.max_wait (default "+facedetect.DefaultBatching.MaxWait.String()+")")
+}
+
+// defaultTileOverlap is wide enough that a face of a fifth of the tile size
+// lies inside some tile.
+const defaultTileOverlap = 0.2
+
+// mergeTiling overlays the tiling flags that were set onto base, the
+// configured values.
+func (f *localFlags) mergeTiling(base *facedetect.Tiling) (facedetect.Tiling, error) {
+	var t facedetect.Tiling
+	if base != nil {
+		t = *base
+	}
+	if f.tiling.Size != 0 {
+		t.Size = f.tiling.Size
+	}
+	if f.tiling.Overlap != 0 {
+		t.Overlap = f.tiling.Overlap
+	}
+	if t.Size > 0 && t.Overlap == 0 {
+		t.Overlap = defaultTileOverlap
+	}
+	if err := t.Check(); err != nil {
+		return t, usageError("%v", err)
+	}
+	return t, nil
+}
+
+// mergeBatch overlays the batching flags that were set onto base, the
+// configured values.
+func (f *localFlags) mergeBatch(base *facedetect.Batching) (facedetect.Batching, error) {
+	var b facedetect.Batching
+	if base != nil {
+		b = *base
+	}
+	if f.batch.Size != 0 {
+		b.Size = f.batch.Size
+	}
+	if f.batch.MaxWait != 0 {
+		b.MaxWait = f.batch.MaxWait
+	}
+	if err := b.Check(); err != nil {
+		return b, usageError("%v", err)
+	}
+	return b, nil
+}
+
+// mergePost overlays the filter flags that were set onto base, the
+// configured values.
+func (f *localFlags) mergePost(base *facedetect.PostProcess) (facedetect.PostProcess, error) {
+	var p facedetect.PostProcess
+	if base != nil {
+		p = *base
+	}
+	if f.p.Threshold != 0 {
+		p.Threshold = f.p.Threshold
+	}
+	if f.p.NMS != "" {
+		p.NMS = f.p.NMS
+	}
+	if f.p.IoU != 0 {
+		p.IoU = f.p.IoU
+	}
+	if f.p.SoftSigma != 0 {
+		p.SoftSigma = f.p.SoftSigma
+	}
+	if f.p.MaxFaces != 0 {
+		p.MaxFaces = f.p.MaxFaces
+	}
+	if f.p.MinFaceSize != 0 {
+		p.MinFaceSize = f.p.MinFaceSize
+	}
+	if err := p.Check(); err != nil {
+		return p, usageError("%v", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,112 @@
+// Command facecap captures frames from a camera or video source and runs one
+// of the face detection backends over them.
+//
+// How to run:
+//
+//	facecap run --source 0 --detector baidu --frames 50 --out out/
+//	facecap bench --source testdata/*.jpg --detector caffe --model res10.caffemodel
+//	facecap inspect --source 0
+//	facecap multi --source 0 --source 1 --model res10.caffemodel --workers 4
+//	facecap mock-server --model res10.caffemodel --latency 300ms --qps 2
+//
+// Run "facecap help <command>" for the flags of each command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is a facecap subcommand. run receives the arguments following the
+// command name and a context that is cancelled on SIGINT or SIGTERM.
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"run":         {"capture frames, detect faces and save annotated images", runCmd},
+	"bench":       {"measure detector latency over a number of frames", benchCmd},
+	"inspect":     {"print the properties of a source and the faces in its first frame", inspectCmd},
+	"multi":       {"run the local detector over several cameras concurrently", multiCmd},
+	"mock-server": {"emulate the remote detection services with a local detector", mockServerCmd},
+}
+
+// errUsage marks errors caused by bad arguments; they exit with status 2.
+var errUsage = errors.New("usage")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: facecap <command> [flags]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"facecap help <command>\" for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if name == "help" || name == "-h" || name == "--help" {
+		if len(args) == 0 {
+			usage()
+			return
+		}
+		name, args = args[0], []string{"-h"}
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "facecap: unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signalContext()
+	err := cmd.run(ctx, args)
+	stop()
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "facecap %s: %v\n", name, redactor.Replace(err.Error()))
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// sourceUsage documents the --source flag shared by all commands.
+const sourceUsage = "frame source: device ID, video file, image directory or glob, rtsp/http URL, or synthetic[:WxH[:N]]"
+
+// usageError wraps a validation failure so main exits with status 2.
+func usageError(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, a...))
+}
+
+// parseFlags parses args into fs and rejects stray positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if fs.NArg() > 0 {
+		return usageError("unexpected arguments: %v", fs.Args())
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/mockserver"
+)
+
+func mockServerCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("mock-server", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "address to serve on")
+	var cfg mockserver.Config
+	fs.DurationVar(&cfg.Latency, "latency", 0, "delay added to every detect answer")
+	fs.DurationVar(&cfg.Jitter, "jitter", 0, "random extra delay of up to this much")
+	fs.Float64Var(&cfg.ErrorRate, "error-rate", 0, "share of detect requests failing with HTTP 500, 0..1")
+	fs.Float64Var(&cfg.BackendErrorRate, "backend-error-rate", 0, "share of detect requests answered with Baidu error_code 282000, 0..1")
+	fs.Float64Var(&cfg.QPS, "qps", 0, "detect requests allowed per second before answering with the QPS limit error, 0 for no limit")
+	fs.StringVar(&cfg.Token, "token", "", "access token to issue and demand (default any token is accepted)")
+	fs.StringVar(&cfg.Username, "username", "", "basic auth user the ibm route demands (default any credentials are accepted)")
+	fs.StringVar(&cfg.Password, "password", "", "basic auth password the ibm route demands")
+	var det detectorFlags
+	det.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if cfg.Latency < 0 || cfg.Jitter < 0 || cfg.QPS < 0 {
+		return usageError("--latency, --jitter and --qps must not be negative")
+	}
+	if cfg.ErrorRate < 0 || cfg.BackendErrorRate < 0 || cfg.ErrorRate+cfg.BackendErrorRate > 1 {
+		return usageError("--error-rate and --backend-error-rate must be between 0 and 1 together")
+	}
+	if cfg.Password != "" && cfg.Username == "" {
+		return usageError("--password needs --username")
+	}
+	if err := det.validate(); err != nil {
+		return err
+	}
+
+	detector, err := det.open()
+	if err != nil {
+		return err
+	}
+	defer closeDetector(detector)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	srv := mockserver.New(detector, cfg)
+	httpSrv := &http.Server{Handler: srv}
+
+	base := "http://" + ln.Addr().String()
+	fmt.Printf("Serving %s detections on %s\n", det.name, base)
+	fmt.Printf("  baidu:     %s/rest/2.0/face/v3/detect (token_url %s/oauth/2.0/token)\n", base, base)
+	fmt.Printf("  fdn-baidu: %s/fdn-baidu\n", base)
+	fmt.Printf("  zz:        %s/zz\n", base)
+	fmt.Printf("  ibm:       %s/ibm\n", base)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- httpSrv.Serve(ln)
+	}()
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = httpSrv.Shutdown(shutdownCtx)
+		cancel()
+	}
+	fmt.Printf("Mock server: %v\n", srv.Stats())
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	return err
+}
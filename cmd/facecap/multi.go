@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kkxu52452/videoCapAndProccess/MultiCameraLocal"
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"gocv.io/x/gocv"
+)
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func multiCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("multi", flag.ContinueOnError)
+	var sources stringList
+	fs.Var(&sources, "source", sourceUsage+"; repeat once per camera")
+	frames := fs.Int("frames", 50, "frames per camera, 0 to read until the source ends")
+	out := fs.String("out", ".", "output root, camera i writes to <out>/cam<i>")
+	nets := fs.Int("nets", 1, "network instances shared by the workers")
+	workers := fs.Int("workers", 2, "concurrent detections, with --batch-size at least the batch size times --nets to fill the batches")
+	jsonl := fs.Bool("jsonl", false, "write <out>/cam<i>/detections.jsonl with one record per frame")
+	model := fs.String("model", "", "model weights, Caffe .caffemodel, TensorFlow .pb or .onnx")
+	config := fs.String("config", "", "network description (default the manifest's or "+facedetect.DefaultManifest.Config+")")
+	manifest := fs.String("manifest", "", "YAML or JSON manifest of the model (default <model>.yaml)")
+	backend := fs.String("backend", "", "OpenCV DNN backend")
+	target := fs.String("target", "", "OpenCV DNN target")
+	var local localFlags
+	local.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	switch {
+	case len(sources) == 0:
+		return usageError("at least one --source is required")
+	case *model == "":
+		return usageError("--model is required")
+	case *frames < 0:
+		return usageError("--frames must not be negative, got %d", *frames)
+	case *nets < 1:
+		return usageError("--nets must be at least 1, got %d", *nets)
+	case *workers < 1:
+		return usageError("--workers must be at least 1, got %d", *workers)
+	}
+	postProcess, err := local.mergePost(nil)
+	if err != nil {
+		return err
+	}
+	tiling, err := local.mergeTiling(nil)
+	if err != nil {
+		return err
+	}
+	batch, err := local.mergeBatch(nil)
+	if err != nil {
+		return err
+	}
+
+	cfg := MultiCameraLocal.Config{
+		Sources:   sources,
+		Model:     *model,
+		NetConfig: *config,
+		Manifest:  *manifest,
+		Post:      postProcess,
+		Tiling:    tiling,
+		Batch:     batch,
+		Backend:   gocv.NetBackendDefault,
+		Target:    gocv.NetTargetCPU,
+		Nets:      *nets,
+		Workers:   *workers,
+		Frames:    *frames,
+		Out:       *out,
+		JSONL:     *jsonl,
+	}
+	if *backend != "" {
+		cfg.Backend = gocv.ParseNetBackend(*backend)
+	}
+	if *target != "" {
+		cfg.Target = gocv.ParseNetTarget(*target)
+	}
+
+	stats, err := MultiCameraLocal.Run(ctx, cfg)
+	for i := range stats {
+		fmt.Println(stats[i].String())
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/capture"
+	"github.com/kkxu52452/videoCapAndProccess/config"
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"github.com/kkxu52452/videoCapAndProccess/output"
+	"github.com/kkxu52452/videoCapAndProccess/pipeline"
+	"github.com/kkxu52452/videoCapAndProccess/transport"
+	"gocv.io/x/gocv"
+)
+
+// color for the rect when faces detected
+var blue = color.RGBA{0, 0, 255, 0}
+
+func runCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	source := fs.String("source", "0", sourceUsage)
+	frames := fs.Int("frames", 50, "number of frames to process, 0 to run until the source ends or the process is interrupted")
+	out := fs.String("out", ".", "directory for the annotated images")
+	images := fs.Bool("images", true, "save every annotated frame as <out>/<n>.jpg")
+	jsonl := fs.String("jsonl", "", "write one JSON detection record per frame to this file, - for stdout")
+	depth := fs.Int("pipeline", 1, "detect requests kept in flight, more hide the latency of a remote detector")
+	chips := fs.Bool("chips", false, "also save every face, cropped and turned upright, as <out>/<n>-<k>.jpg")
+	attributes := fs.Bool("attributes", false, "draw the landmarks and attributes a Baidu detector returns, see --face-field")
+	unordered := fs.Bool("unordered", false, "with --pipeline, handle frames as their results arrive instead of in frame order")
+	var video videoFlags
+	video.register(fs)
+	var det detectorFlags
+	det.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *frames < 0 {
+		return usageError("--frames must not be negative, got %d", *frames)
+	}
+	if *depth < 1 {
+		return usageError("--pipeline must be at least 1, got %d", *depth)
+	}
+	if err := det.validate(); err != nil {
+		return err
+	}
+	if err := video.validate(); err != nil {
+		return err
+	}
+	if *images || *chips {
+		if err := os.MkdirAll(*out, 0755); err != nil {
+			return err
+		}
+	}
+
+	// keep stdout clean for the records when they are written there
+	logw := io.Writer(os.Stdout)
+	if *jsonl == "-" {
+		logw = os.Stderr
+	}
+
+	var records *output.JSONL
+	if *jsonl != "" {
+		var err error
+		records, err = output.CreateJSONL(*jsonl)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := records.Close(); err != nil {
+				fmt.Fprintf(logw, "[ERR] jsonl: %v\n", err)
+			}
+		}()
+	}
+
+	writer, err := video.open()
+	if err != nil {
+		return err
+	}
+	if writer != nil {
+		defer func() {
+			if err := writer.Close(); err != nil {
+				fmt.Fprintf(logw, "[ERR] video: %v\n", err)
+			}
+			fmt.Fprintf(logw, "Video: %d frames written at %.2f frames/s\n", writer.Frames(), writer.FPS())
+		}()
+	}
+
+	detector, err := det.open()
+	if err != nil {
+		return err
+	}
+	defer closeDetector(detector)
+
+	src, sourceName, err := openSource(*source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fmt.Fprintf(logw, "Start reading source: %v\n", config.RedactURL(fmt.Sprint(src)))
+
+	// a live source is read continuously to keep the buffer updated, a
+	// recorded one frame by frame so that none is skipped
+	live := capture.IsLive(src)
+	var buf *capture.Latest
+	if live {
+		buf = capture.NewLatest()
+		grabCtx, cancel := context.WithCancel(ctx)
+		grabbed := make(chan struct{})
+		go func() {
+			buf.Grab(grabCtx, src)
+			close(grabbed)
+		}()
+		defer func() {
+			cancel()
+			<-grabbed
+			buf.Release()
+		}()
+	}
+
+	// frames are read while earlier ones are still being detected, readCtx
+	// stops the reading when the output fails
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+	pipe := pipeline.New(ctx, detector, pipeline.Config{Depth: *depth, Unordered: *unordered})
+
+	var skipped int
+	var readReason string
+	go func() {
+		defer pipe.Close()
+		readReason = "frame limit reached"
+		var seq uint64
+		for i := 0; *frames == 0 || i < *frames; i++ {
+			// take the slot before the frame, a live frame should not wait
+			if err := pipe.Reserve(readCtx); err != nil {
+				readReason = "interrupted"
+				return
+			}
+			f := pipeline.Frame{Index: i, Mat: gocv.NewMat()}
+			if live {
+				frame, err := buf.Wait(readCtx, seq, &f.Mat)
+				if err != nil {
+					f.Mat.Close()
+					pipe.Release()
+					readReason = fmt.Sprintf("device closed: %v", buf.Err())
+					if readCtx.Err() != nil {
+						readReason = "interrupted"
+					}
+					return
+				}
+				if seq > 0 {
+					skipped += int(frame.Seq - seq - 1)
+				}
+				seq = frame.Seq
+				f.Seq, f.Time = frame.Seq, frame.Time
+			} else if err := capture.ReadContext(readCtx, src, &f.Mat); err != nil {
+				f.Mat.Close()
+				pipe.Release()
+				switch {
+				case err == io.EOF:
+					readReason = "end of source"
+					return
+				case readCtx.Err() != nil:
+					readReason = "interrupted"
+					return
+				}
+				fmt.Fprintf(logw, "[ERR] frame %d: %v\n", i, err)
+				continue
+			} else {
+				f.Time = time.Now()
+			}
+			pipe.Submit(f)
+		}
+	}()
+
+	sum := newSummary()
+	process := func(r pipeline.Result) error {
+		sum.add(len(r.Faces), r.Err, r.Latency)
+		if r.Err != nil && !errors.Is(r.Err, facedetect.ErrDropped) {
+			fmt.Fprintf(logw, "[ERR] frame %d: %v\n", r.Index, r.Err)
+		}
+		if records != nil {
+			rec := output.NewRecord(r.Index, r.Time, sourceName, det.name, r.Latency, r.Faces, r.Err)
+			rec.Seq = r.Seq
+			if err := records.Write(rec); err != nil {
+				return fmt.Errorf("jsonl output failed: %v", err)
+			}
+		}
+
+		if *chips {
+			saveChips(*out, r.Index, r.Mat, r.Faces)
+		}
+		annotate(&r.Mat, r.Faces, r.Err, r.Latency)
+		if *attributes {
+			facedetect.DrawAttributes(&r.Mat, r.Faces, blue)
+		}
+		if *images {
+			gocv.IMWrite(filepath.Join(*out, fmt.Sprintf("%d.jpg", r.Index)), r.Mat)
+		}
+		if writer != nil {
+			if err := writer.Write(r.Mat, r.Time); err != nil {
+				return fmt.Errorf("video output failed: %v", err)
+			}
+		}
+		return nil
+	}
+
+	// every result is received so that its frame is released. After an
+	// interrupt the frames already detected are still written out; after a
+	// failure nothing is processed any more
+	var failure error
+	for r := range pipe.Results() {
+		// a call aborted by the interrupt has no result
+		aborted := ctx.Err() != nil && errors.Is(r.Err, context.Canceled)
+		if failure == nil && !aborted {
+			if failure = process(r); failure != nil {
+				stopReading()
+			}
+		}
+		r.Mat.Close()
+	}
+	sum.skipped += skipped
+
+	reason := readReason
+	switch {
+	case failure != nil:
+		reason = failure.Error()
+	case ctx.Err() != nil:
+		reason = "interrupted"
+	}
+
+	sum.print(logw, reason)
+	if *depth > 1 {
+		fmt.Fprintf(logw, "Pipeline: %v\n", pipe.Stats())
+	}
+	if det.resilient != nil {
+		fmt.Fprintf(logw, "Remote calls: %v\n", det.resilient.Stats())
+	}
+	if det.limiter != nil {
+		fmt.Fprintf(logw, "Rate limit: %v\n", det.limiter.Stats())
+	}
+	if det.batcher != nil {
+		fmt.Fprintf(logw, "Batches: %v\n", det.batcher.Stats())
+	}
+	if stats, ok := transport.Stats(det.client); ok {
+		fmt.Fprintf(logw, "Connections: %v\n", stats)
+	}
+	return nil
+}
+
+// saveChips writes the upright crop of each face in img.
+func saveChips(dir string, frame int, img gocv.Mat, faces []facedetect.Face) {
+	for k, f := range faces {
+		chip := facedetect.Chip(img, f)
+		if !chip.Empty() {
+			gocv.IMWrite(filepath.Join(dir, fmt.Sprintf("%d-%d.jpg", frame, k)), chip)
+		}
+		chip.Close()
+	}
+}
+
+// annotate draws the detection result and its timing onto img.
+func annotate(img *gocv.Mat, faces []facedetect.Face, err error, elapsed time.Duration) {
+	status := fmt.Sprintf("%d faces", len(faces))
+	if err != nil {
+		status = err.Error()
+	}
+	imgText := fmt.Sprintf("Result: %s, Time Consumed: %s, Current Time: %s", status, elapsed, time.Now().UTC())
+	gocv.PutText(img, imgText, image.Point{50, 50}, gocv.FontHersheyPlain, 1.8, blue, 2)
+	facedetect.DrawFaces(img, faces, blue, 3)
+}
+
+// openSource opens the capture source spec. It also returns the spec with
+// the credentials of a stream URL masked, to be used in logs and records in
+// its place; errors are masked the same way.
+func openSource(spec string) (capture.FrameSource, string, error) {
+	name := config.RedactURL(spec)
+	src, err := capture.Open(spec)
+	if err != nil {
+		return nil, name, config.RedactError(strings.NewReplacer(spec, name), err)
+	}
+	return src, name, nil
+}
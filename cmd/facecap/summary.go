@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+)
+
+// summary accumulates the outcome of a capture run for the exit report.
+type summary struct {
+	start   time.Time
+	frames  int // frames detected successfully
+	errors  int // frames whose detection failed
+	faces   int
+	skipped int // frames passed over: live frames that arrived during a detection, or dropped by the rate limit
+	detect  time.Duration
+	kinds   map[string]int // failures by facedetect.ErrorKind
+}
+
+func newSummary() *summary {
+	return &summary{start: time.Now(), kinds: make(map[string]int)}
+}
+
+// add records the result of one detect call.
+func (s *summary) add(faces int, err error, elapsed time.Duration) {
+	if errors.Is(err, facedetect.ErrDropped) {
+		s.skipped++
+		return
+	}
+	if err != nil {
+		s.errors++
+		s.kinds[facedetect.ErrorKind(err)]++
+		return
+	}
+	s.frames++
+	s.faces += faces
+	s.detect += elapsed
+}
+
+// print writes the report; reason tells why the run ended.
+func (s *summary) print(w io.Writer, reason string) {
+	total := time.Since(s.start)
+	fmt.Fprintf(w, "Stopped: %s after %s\n", reason, total.Round(time.Millisecond))
+	fmt.Fprintf(w, "Frames: %d detected, %d failed, %d skipped; faces: %d\n", s.frames, s.errors, s.skipped, s.faces)
+	if len(s.kinds) > 0 {
+		fmt.Fprintf(w, "Failures: %s\n", formatCounts(s.kinds))
+	}
+	if s.frames > 0 {
+		fmt.Fprintf(w, "Average detect time: %s, throughput: %.2f frames/s\n",
+			s.detect/time.Duration(s.frames), float64(s.frames)/total.Seconds())
+	}
+}
+
+// formatCounts renders counts as "a=1 b=2" in key order.
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/output"
+)
+
+// videoFlags configures the annotated video output of the run command.
+type videoFlags struct {
+	path       string
+	codec      string
+	fps        float64
+	segmentDur time.Duration
+	segmentMB  int
+}
+
+func (v *videoFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&v.path, "video", "", "write annotated frames to this video file (.avi, .mp4)")
+	fs.StringVar(&v.codec, "codec", "", "video FourCC, e.g. MJPG or mp4v (default chosen from the file extension)")
+	fs.Float64Var(&v.fps, "fps", 0, "video frame rate, 0 to measure it from the capture times")
+	fs.DurationVar(&v.segmentDur, "segment-duration", 0, "start a new video file after this long, e.g. 10m")
+	fs.IntVar(&v.segmentMB, "segment-mb", 0, "start a new video file once it exceeds this many MiB")
+}
+
+func (v *videoFlags) validate() error {
+	switch {
+	case v.path == "" && (v.codec != "" || v.fps != 0 || v.segmentDur != 0 || v.segmentMB != 0):
+		return usageError("video options need --video")
+	case v.fps < 0:
+		return usageError("--fps must not be negative, got %v", v.fps)
+	case v.codec != "" && len(v.codec) != 4:
+		return usageError("--codec must be a FourCC, got %q", v.codec)
+	case v.segmentDur < 0:
+		return usageError("--segment-duration must not be negative, got %v", v.segmentDur)
+	case v.segmentMB < 0:
+		return usageError("--segment-mb must not be negative, got %d", v.segmentMB)
+	}
+	return nil
+}
+
+// open returns the video output, or nil when none was asked for.
+func (v *videoFlags) open() (*output.Video, error) {
+	if v.path == "" {
+		return nil, nil
+	}
+	return output.NewVideo(output.VideoConfig{
+		Path:            v.path,
+		Codec:           v.codec,
+		FPS:             v.fps,
+		SegmentDuration: v.segmentDur,
+		SegmentSize:     int64(v.segmentMB) << 20,
+	})
+}
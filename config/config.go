@@ -0,0 +1,303 @@
+// Package config loads the settings of the detection backends: endpoints,
+// credentials, TLS and connection options. Values come from a YAML file, an
+// optional secrets file in the same format (e.g. a mounted Kubernetes
+// secret) and environment variables, each overriding the one before.
+//
+// Environment variables are named FACECAP_<BACKEND>_<FIELD>, with the
+// backend name upper-cased and dashes replaced by underscores, for example
+// FACECAP_BAIDU_SECRET_KEY or FACECAP_FDN_BAIDU_URL.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"github.com/kkxu52452/videoCapAndProccess/transport"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix starts the name of every override variable.
+const envPrefix = "FACECAP_"
+
+// Backend holds the settings of a remote detection backend. Fields a
+// backend does not use are ignored.
+type Backend struct {
+	URL        string              `yaml:"url"`
+	Username   string              `yaml:"username"`   // basic auth, IBM Cloud Functions
+	Password   string              `yaml:"password"`   // secret
+	APIKey     string              `yaml:"api_key"`    // Baidu
+	SecretKey  string              `yaml:"secret_key"` // secret
+	TokenURL   string              `yaml:"token_url"`
+	TokenCache string              `yaml:"token_cache"`
+	TLS        transport.TLSConfig `yaml:"tls"`
+
+	// TokenTimeout bounds each Baidu token request, 0 for 30 seconds.
+	TokenTimeout time.Duration `yaml:"token_timeout"`
+
+	// HTTP tunes the backend's connections, see transport.DefaultClient.
+	HTTP transport.ClientConfig `yaml:"http"`
+
+	// Params are passed to Baidu by the baidu, fdn-baidu and ibm backends.
+	Params facedetect.BaiduParams `yaml:"params"`
+
+	// RateLimit paces requests; nil leaves the backend's default.
+	RateLimit *facedetect.RateLimitConfig `yaml:"rate_limit"`
+}
+
+// Local holds the settings of the local DNN detector.
+type Local struct {
+	Model    string `yaml:"model"`
+	Config   string `yaml:"config"`
+	Manifest string `yaml:"manifest"` // default: next to the model, see facedetect.ResolveManifest
+	Backend  string `yaml:"backend"`
+	Target   string `yaml:"target"`
+
+	// PostProcess filters the detections, Tiling adds passes over parts of
+	// the frame and Batch runs concurrent frames together; nil leaves the
+	// defaults.
+	PostProcess *facedetect.PostProcess `yaml:"postprocess"`
+	Tiling      *facedetect.Tiling      `yaml:"tiling"`
+	Batch       *facedetect.Batching    `yaml:"batch"`
+}
+
+// Config is the complete configuration.
+type Config struct {
+	Backends map[string]*Backend `yaml:"backends"`
+	Local    Local               `yaml:"local"`
+}
+
+// Load reads the configuration file at path and the secrets file at
+// secretsPath, then applies environment overrides. Either path may be empty
+// to skip that file.
+func Load(path, secretsPath string) (*Config, error) {
+	c := &Config{}
+	for _, p := range []string{path, secretsPath} {
+		if p == "" {
+			continue
+		}
+		if err := c.merge(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Backend returns the settings of the named backend, never nil.
+func (c *Config) Backend(name string) *Backend {
+	if c.Backends == nil {
+		c.Backends = make(map[string]*Backend)
+	}
+	b, ok := c.Backends[name]
+	if !ok {
+		b = &Backend{}
+		c.Backends[name] = b
+	}
+	return b
+}
+
+// merge overlays the non-empty values of the YAML file at path onto c.
+func (c *Config) merge(path string) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f Config
+	if err := yaml.UnmarshalStrict(buf, &f); err != nil {
+		return fmt.Errorf("config %v: %v", path, err)
+	}
+
+	for name, src := range f.Backends {
+		if src == nil {
+			continue
+		}
+		dst := c.Backend(name)
+		for key, v := range src.fields() {
+			if *v != "" && !strings.HasPrefix(key, "TLS_") && !strings.HasPrefix(key, "HTTP_") {
+				*dst.fields()[key] = *v
+			}
+		}
+		mergeTLS(&dst.TLS, &src.TLS)
+		mergeHTTP(&dst.HTTP, &src.HTTP)
+		if src.Params.MaxFaceNum != 0 {
+			dst.Params.MaxFaceNum = src.Params.MaxFaceNum
+		}
+		if src.TokenTimeout != 0 {
+			dst.TokenTimeout = src.TokenTimeout
+		}
+		if src.RateLimit != nil {
+			dst.RateLimit = src.RateLimit
+		}
+	}
+	for key, v := range f.Local.fields() {
+		if *v != "" {
+			*c.Local.fields()[key] = *v
+		}
+	}
+	if f.Local.PostProcess != nil {
+		c.Local.PostProcess = f.Local.PostProcess
+	}
+	if f.Local.Tiling != nil {
+		c.Local.Tiling = f.Local.Tiling
+	}
+	if f.Local.Batch != nil {
+		c.Local.Batch = f.Local.Batch
+	}
+	return nil
+}
+
+// applyEnv applies the FACECAP_ variables of environ.
+func (c *Config) applyEnv(environ []string) error {
+	for _, kv := range environ {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv, envPrefix) {
+			continue
+		}
+		name, value := kv[:i], kv[i+1:]
+		key := name[len(envPrefix):]
+
+		if field := strings.TrimPrefix(key, "LOCAL_"); field != key {
+			if v, ok := c.Local.fields()[field]; ok {
+				*v = value
+			}
+			continue
+		}
+		for _, backend := range knownBackends {
+			prefix := EnvVar(backend, "")
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			b := c.Backend(backend)
+			field := name[len(prefix):]
+			switch v, ok := b.fields()[field]; {
+			case ok:
+				*v = value
+			case field == "TLS_PINS":
+				b.TLS.Pins = strings.Split(value, ",")
+			case field == "MAX_FACE_NUM":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+				b.Params.MaxFaceNum = n
+			case field == "TLS_INSECURE_SKIP_VERIFY":
+				insecure, err := strconv.ParseBool(value)
+				if err != nil {
+					return fmt.Errorf("%s: %v", name, err)
+				}
+				b.TLS.InsecureSkipVerify = insecure
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// knownBackends are the backends environment variables are matched against.
+// The longer name comes first so FDN_BAIDU_ is not read as a BAIDU_ field.
+var knownBackends = []string{"fdn-baidu", "baidu", "zz", "ibm"}
+
+// EnvVar names the environment variable overriding field of backend, for
+// example EnvVar("fdn-baidu", "URL") is FACECAP_FDN_BAIDU_URL.
+func EnvVar(backend, field string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(backend, "-", "_", -1)) + "_" + field
+}
+
+// fields maps the variable suffix of each string setting to its value.
+func (b *Backend) fields() map[string]*string {
+	return map[string]*string{
+		"URL":         &b.URL,
+		"USERNAME":    &b.Username,
+		"PASSWORD":    &b.Password,
+		"API_KEY":     &b.APIKey,
+		"SECRET_KEY":  &b.SecretKey,
+		"TOKEN_URL":   &b.TokenURL,
+		"TOKEN_CACHE": &b.TokenCache,
+
+		"FACE_FIELD":       &b.Params.FaceField,
+		"FACE_TYPE":        &b.Params.FaceType,
+		"LIVENESS_CONTROL": &b.Params.LivenessControl,
+
+		"TLS_CA_FILE":     &b.TLS.CAFile,
+		"TLS_CERT_FILE":   &b.TLS.CertFile,
+		"TLS_KEY_FILE":    &b.TLS.KeyFile,
+		"TLS_SERVER_NAME": &b.TLS.ServerName,
+
+		"HTTP_PROXY": &b.HTTP.Proxy,
+	}
+}
+
+// mergeTLS overlays the values set in src onto dst.
+func mergeTLS(dst, src *transport.TLSConfig) {
+	for _, f := range []struct{ dst, src *string }{
+		{&dst.CAFile, &src.CAFile},
+		{&dst.CertFile, &src.CertFile},
+		{&dst.KeyFile, &src.KeyFile},
+		{&dst.ServerName, &src.ServerName},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if len(src.Pins) > 0 {
+		dst.Pins = src.Pins
+	}
+	if src.InsecureSkipVerify {
+		dst.InsecureSkipVerify = true
+	}
+}
+
+// mergeHTTP overlays the values set in src onto dst.
+func mergeHTTP(dst, src *transport.ClientConfig) {
+	if src.Proxy != "" {
+		dst.Proxy = src.Proxy
+	}
+	for _, f := range []struct{ dst, src *int }{
+		{&dst.MaxIdleConns, &src.MaxIdleConns},
+		{&dst.MaxConns, &src.MaxConns},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+	for _, f := range []struct{ dst, src *time.Duration }{
+		{&dst.IdleTimeout, &src.IdleTimeout},
+		{&dst.DialTimeout, &src.DialTimeout},
+		{&dst.TLSHandshakeTimeout, &src.TLSHandshakeTimeout},
+		{&dst.ResponseHeaderTimeout, &src.ResponseHeaderTimeout},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+	for _, f := range []struct{ dst, src *int64 }{
+		{&dst.MaxRequestBytes, &src.MaxRequestBytes},
+		{&dst.MaxResponseBytes, &src.MaxResponseBytes},
+	} {
+		if *f.src != 0 {
+			*f.dst = *f.src
+		}
+	}
+	if src.DisableHTTP2 {
+		dst.DisableHTTP2 = true
+	}
+	if src.DisableKeepAlives {
+		dst.DisableKeepAlives = true
+	}
+}
+
+func (l *Local) fields() map[string]*string {
+	return map[string]*string{
+		"MODEL":    &l.Model,
+		"CONFIG":   &l.Config,
+		"MANIFEST": &l.Manifest,
+		"BACKEND":  &l.Backend,
+		"TARGET":   &l.Target,
+	}
+}
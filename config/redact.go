@@ -0,0 +1,106 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// redacted replaces secrets in logs and error messages.
+const redacted = "***"
+
+// secrets returns the secret values of the configuration: passwords, secret
+// keys, the IBM function key and credentials embedded in URLs, the proxy's
+// included.
+func (c *Config) secrets() []string {
+	var s []string
+	for _, b := range c.Backends {
+		// the IBM username is the first half of the function's API key
+		s = append(s, b.Username, b.Password, b.SecretKey)
+		s = append(s, urlSecrets(b.URL)...)
+		s = append(s, urlSecrets(b.HTTP.Proxy)...)
+	}
+	return s
+}
+
+// urlSecrets returns the user, password and access_token of a URL. A user
+// without a password is often a token itself.
+func urlSecrets(raw string) []string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	s := []string{u.Query().Get("access_token")}
+	if u.User != nil {
+		s = append(s, u.User.Username())
+		if p, ok := u.User.Password(); ok {
+			s = append(s, p)
+		}
+	}
+	return s
+}
+
+// Redactor returns a replacer masking every secret of c. It is meant for
+// anything that may end up in a log: error messages, overlay text and
+// detection records.
+func (c *Config) Redactor() *strings.Replacer {
+	secrets := c.secrets()
+	// replace longer secrets first so that overlapping ones are fully masked
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	var pairs []string
+	for _, s := range secrets {
+		// very short values would mask unrelated text
+		if len(s) >= 4 {
+			pairs = append(pairs, s, redacted)
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// RedactError returns err with its message passed through r. The original
+// error is still available to errors.Is and errors.As.
+func RedactError(r *strings.Replacer, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{msg: r.Replace(err.Error()), err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// RedactURL masks the password and the access_token query of a URL, such
+// as a stream source with credentials, and a user given without a password,
+// which is often a token. Anything else, device numbers and file names
+// included, is returned as it is.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	masked := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+		masked = true
+	} else if u.User != nil && u.User.Username() != "" {
+		u.User = url.User(redacted)
+		masked = true
+	}
+	q := u.Query()
+	if q.Get("access_token") != "" {
+		q.Set("access_token", redacted)
+		u.RawQuery = q.Encode()
+		masked = true
+	}
+	if !masked {
+		return raw
+	}
+	// the URL escapes the mask, undo that for readability
+	return strings.Replace(u.String(), url.QueryEscape(redacted), redacted, -1)
+}
@@ -0,0 +1,125 @@
+package facedetect
+
+import (
+	"image"
+	"sort"
+)
+
+// Attributes are the optional face attributes of a Baidu answer. Baidu
+// returns most of them only when asked for with BaiduParams.FaceField, e.g.
+// "age,gender,expression,glasses,quality,landmark150". A local YuNet model
+// reports the five landmarks it finds here.
+type Attributes struct {
+	Token      string     `json:"face_token,omitempty"`
+	Age        float64    `json:"age,omitempty"`
+	Beauty     float64    `json:"beauty,omitempty"`     // 0..100
+	Expression string     `json:"expression,omitempty"` // none, smile or laugh
+	Gender     string     `json:"gender,omitempty"`     // male or female
+	Glasses    string     `json:"glasses,omitempty"`    // none, common or sun
+	Angle      *Angle     `json:"angle,omitempty"`
+	Quality    *Quality   `json:"quality,omitempty"`
+	Landmarks  []Landmark `json:"landmarks,omitempty"` // the landmark72, landmark150 or YuNet points
+}
+
+// Angle is the head pose in degrees.
+type Angle struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+	Roll  float64 `json:"roll"`
+}
+
+// Quality rates how usable the face image is.
+type Quality struct {
+	Blur         float64   `json:"blur"`         // 0 sharp .. 1 blurred
+	Illumination float64   `json:"illumination"` // 0 dark .. 255 bright
+	Completeness float64   `json:"completeness"` // 1 when the face lies inside the frame
+	Occlusion    Occlusion `json:"occlusion"`
+}
+
+// Occlusion is the covered share of each face region, 0..1.
+type Occlusion struct {
+	LeftEye    float64 `json:"left_eye"`
+	RightEye   float64 `json:"right_eye"`
+	Nose       float64 `json:"nose"`
+	Mouth      float64 `json:"mouth"`
+	LeftCheek  float64 `json:"left_cheek"`
+	RightCheek float64 `json:"right_cheek"`
+	Chin       float64 `json:"chin_contour"`
+}
+
+// Landmark is a facial key point in pixels of the analysed frame.
+type Landmark struct {
+	// Name is the key of a landmark150 point, e.g. "eye_left_corner_left";
+	// the landmark72 and YuNet points are known by their index instead
+	Name string  `json:"name,omitempty"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// Pt returns l rounded to a pixel.
+func (l Landmark) Pt() image.Point {
+	return image.Pt(int(l.X+0.5), int(l.Y+0.5))
+}
+
+// baiduLabel is a classified attribute such as gender.
+type baiduLabel struct {
+	Type        string  `json:"type"`
+	Probability float64 `json:"probability"`
+}
+
+func (l *baiduLabel) String() string {
+	if l == nil {
+		return ""
+	}
+	return l.Type
+}
+
+// baiduAttributes are the face_field parts of a face in a Baidu answer.
+type baiduAttributes struct {
+	FaceToken   string              `json:"face_token"`
+	Age         float64             `json:"age"`
+	Beauty      float64             `json:"beauty"`
+	Expression  *baiduLabel         `json:"expression"`
+	Gender      *baiduLabel         `json:"gender"`
+	Glasses     *baiduLabel         `json:"glasses"`
+	Angle       *Angle              `json:"angle"`
+	Quality     *Quality            `json:"quality"`
+	Landmark72  []Landmark          `json:"landmark72"`
+	Landmark150 map[string]Landmark `json:"landmark150"`
+}
+
+// attributes converts a into Attributes, nil when Baidu sent none.
+func (a *baiduAttributes) attributes() *Attributes {
+	attrs := &Attributes{
+		Token:      a.FaceToken,
+		Age:        a.Age,
+		Beauty:     a.Beauty,
+		Expression: a.Expression.String(),
+		Gender:     a.Gender.String(),
+		Glasses:    a.Glasses.String(),
+		Angle:      a.Angle,
+		Quality:    a.Quality,
+		Landmarks:  a.Landmark72,
+	}
+	if len(a.Landmark150) > 0 {
+		// the 150 points come keyed by name; keep the names and order them
+		// by name for a stable output
+		names := make([]string, 0, len(a.Landmark150))
+		for name := range a.Landmark150 {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		attrs.Landmarks = make([]Landmark, 0, len(names))
+		for _, name := range names {
+			lm := a.Landmark150[name]
+			lm.Name = name
+			attrs.Landmarks = append(attrs.Landmarks, lm)
+		}
+	}
+	if attrs.Token == "" && attrs.Age == 0 && attrs.Beauty == 0 && attrs.Expression == "" &&
+		attrs.Gender == "" && attrs.Glasses == "" && attrs.Angle == nil && attrs.Quality == nil &&
+		len(attrs.Landmarks) == 0 {
+		return nil
+	}
+	return attrs
+}
@@ -0,0 +1,63 @@
+package facedetect
+
+import (
+	"encoding/json"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestBaiduAttributes(t *testing.T) {
+	const answer = `{"error_code":0,"result":{"face_list":[{
+		"location":{"left":0,"top":0,"width":100,"height":100},"face_probability":1,
+		"face_token":"f1","age":31,"gender":{"type":"female","probability":0.99},
+		"angle":{"yaw":-3,"pitch":2,"roll":1},
+		"landmark150":{
+			"nose_tip":{"x":50,"y":55},
+			"eye_left_corner_left":{"x":30,"y":40},
+			"mouth_corner_right_outer":{"x":60,"y":75}
+		}}]}}`
+	var resp baiduResponse
+	if err := json.Unmarshal([]byte(answer), &resp); err != nil {
+		t.Fatal(err)
+	}
+	faces, err := resp.faces(SourceBaidu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Attributes{
+		Token:  "f1",
+		Age:    31,
+		Gender: "female",
+		Angle:  &Angle{Yaw: -3, Pitch: 2, Roll: 1},
+		Landmarks: []Landmark{
+			{Name: "eye_left_corner_left", X: 30, Y: 40},
+			{Name: "mouth_corner_right_outer", X: 60, Y: 75},
+			{Name: "nose_tip", X: 50, Y: 55},
+		},
+	}
+	if !reflect.DeepEqual(faces[0].Attributes, want) {
+		t.Fatalf("got %+v, want %+v", faces[0].Attributes, want)
+	}
+
+	// the names survive moving the face, as the tiles of a local model do
+	moved := faces[0].translate(image.Pt(10, 20))
+	if lm := moved.Attributes.Landmarks[2]; lm != (Landmark{Name: "nose_tip", X: 60, Y: 75}) {
+		t.Fatalf("moved landmark %+v", lm)
+	}
+}
+
+func TestBaiduAttributesLandmark72(t *testing.T) {
+	const answer = `{"landmark72":[{"x":1,"y":2},{"x":3,"y":4}]}`
+	var a baiduAttributes
+	if err := json.Unmarshal([]byte(answer), &a); err != nil {
+		t.Fatal(err)
+	}
+	got := a.attributes()
+	if got == nil || !reflect.DeepEqual(got.Landmarks, []Landmark{{X: 1, Y: 2}, {X: 3, Y: 4}}) {
+		t.Fatalf("got %+v", got)
+	}
+	if (&baiduAttributes{}).attributes() != nil {
+		t.Fatal("an answer without attributes gave attributes")
+	}
+}
@@ -0,0 +1,192 @@
+package facedetect
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"gocv.io/x/gocv"
+)
+
+// baiduNoFace is the error_code Baidu answers with when the picture holds no
+// face; it is a regular empty result, not a failure.
+const baiduNoFace = 222202
+
+type baiduLocation struct {
+	Left     float64 `json:"left"`
+	Top      float64 `json:"top"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation"`
+}
+
+type baiduFace struct {
+	Location    baiduLocation `json:"location"`
+	Probability float64       `json:"face_probability"`
+	baiduAttributes
+}
+
+type baiduResult struct {
+	FaceList []baiduFace `json:"face_list"`
+}
+
+// baiduResponse is the body of a Baidu face detect v3 answer. The FDN and
+// IBM gateways forward it wrapped in their own envelopes.
+type baiduResponse struct {
+	ErrorCode int         `json:"error_code"`
+	ErrorMsg  string      `json:"error_msg"`
+	Result    baiduResult `json:"result"`
+}
+
+// faces converts the response into normalized faces tagged with source.
+func (r *baiduResponse) faces(source string) ([]Face, error) {
+	if r.ErrorCode != 0 && r.ErrorCode != baiduNoFace {
+		return nil, &Error{Backend: source, Kind: baiduCodeKind(r.ErrorCode), Code: r.ErrorCode, Msg: r.ErrorMsg}
+	}
+
+	faces := make([]Face, 0, len(r.Result.FaceList))
+	for _, d := range r.Result.FaceList {
+		loc := d.Location
+		faces = append(faces, Face{
+			Box:        image.Rect(int(loc.Left), int(loc.Top), int(loc.Width+loc.Left), int(loc.Height+loc.Top)),
+			Confidence: d.Probability,
+			Rotation:   loc.Rotation,
+			Source:     source,
+			Attributes: d.attributes(),
+		})
+	}
+	return faces, nil
+}
+
+// BaiduParams are the optional parameters of a Baidu detect request. Zero
+// values are left out so that the API applies its defaults. The FDN and IBM
+// functions forward them to Baidu.
+type BaiduParams struct {
+	FaceField       string `yaml:"face_field"`       // comma-separated extra attributes, e.g. "age,quality"
+	MaxFaceNum      int    `yaml:"max_face_num"`     // faces returned, Baidu's default is 1
+	FaceType        string `yaml:"face_type"`        // LIVE, IDCARD, WATERMARK, CERT or INFRARED
+	LivenessControl string `yaml:"liveness_control"` // NONE, LOW, NORMAL or HIGH
+}
+
+// baiduRequest is the JSON body of a detect request.
+type baiduRequest struct {
+	Image           string `json:"image"`
+	ImageType       string `json:"image_type"`
+	FaceField       string `json:"face_field,omitempty"`
+	MaxFaceNum      int    `json:"max_face_num,omitempty"`
+	FaceType        string `json:"face_type,omitempty"`
+	LivenessControl string `json:"liveness_control,omitempty"`
+}
+
+// json returns the JSON request body for a base64 encoded image.
+func (p *BaiduParams) json(imgBase64 string) (string, error) {
+	buf, err := json.Marshal(baiduRequest{
+		Image:           imgBase64,
+		ImageType:       "BASE64",
+		FaceField:       p.FaceField,
+		MaxFaceNum:      p.MaxFaceNum,
+		FaceType:        p.FaceType,
+		LivenessControl: p.LivenessControl,
+	})
+	return string(buf), err
+}
+
+// form returns the x-www-form-urlencoded request body for a base64 encoded
+// image. The base64 alphabet's + and / must be escaped in a form.
+func (p *BaiduParams) form(imgBase64 string) string {
+	v := url.Values{
+		"image":      {imgBase64},
+		"image_type": {"BASE64"},
+	}
+	if p.FaceField != "" {
+		v.Set("face_field", p.FaceField)
+	}
+	if p.MaxFaceNum > 0 {
+		v.Set("max_face_num", strconv.Itoa(p.MaxFaceNum))
+	}
+	if p.FaceType != "" {
+		v.Set("face_type", p.FaceType)
+	}
+	if p.LivenessControl != "" {
+		v.Set("liveness_control", p.LivenessControl)
+	}
+	return v.Encode()
+}
+
+// Baidu calls the Baidu AI face detect v3 API directly, which takes a JSON
+// body.
+type Baidu struct {
+	URL    string       // detect endpoint, with an access_token query when Token is nil
+	Token  *BaiduToken  // supplies access tokens, nil to use URL as it is
+	Client *http.Client // nil means transport.Default()
+	Params BaiduParams
+}
+
+// NewBaidu returns a detector posting to the Baidu detect endpoint at url,
+// which must carry a valid access_token.
+func NewBaidu(url string) *Baidu {
+	return &Baidu{URL: url}
+}
+
+// NewBaiduWithKeys returns a detector for the public Baidu endpoint that
+// obtains its access tokens from apiKey and secretKey, caching them in
+// cacheFile unless it is empty.
+func NewBaiduWithKeys(apiKey, secretKey, cacheFile string) *Baidu {
+	return &Baidu{
+		URL:   BaiduDetectURL,
+		Token: &BaiduToken{APIKey: apiKey, SecretKey: secretKey, CacheFile: cacheFile},
+	}
+}
+
+// Detect implements Detector. When the API rejects the access token, a new
+// one is fetched and the request is repeated once.
+func (b *Baidu) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	imgBase64, err := encodeBase64(SourceBaidu, img)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := b.Params.json(imgBase64)
+	if err != nil {
+		return nil, &Error{Backend: SourceBaidu, Kind: ErrEncode, Err: err}
+	}
+
+	for attempt := 0; ; attempt++ {
+		endpoint, token, err := b.endpoint(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp baiduResponse
+		if err := post(ctx, b.Client, SourceBaidu, endpoint, contentJSON, payload, &resp); err != nil {
+			return nil, err
+		}
+		if b.Token != nil && attempt == 0 &&
+			(resp.ErrorCode == baiduTokenInvalid || resp.ErrorCode == baiduTokenExpired) {
+			b.Token.Invalidate(token)
+			continue
+		}
+		return resp.faces(SourceBaidu)
+	}
+}
+
+// endpoint returns the URL to post to and the access token it carries.
+func (b *Baidu) endpoint(ctx context.Context) (string, string, error) {
+	if b.Token == nil {
+		return b.URL, "", nil
+	}
+	token, err := b.Token.Token(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return "", "", &Error{Backend: SourceBaidu, Kind: ErrTransport, Msg: "bad URL", Err: err}
+	}
+	q := u.Query()
+	q.Set("access_token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), token, nil
+}
@@ -0,0 +1,276 @@
+package facedetect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/transport"
+)
+
+// Baidu AI cloud endpoints.
+const (
+	BaiduTokenURL  = "https://aip.baidubce.com/oauth/2.0/token"
+	BaiduDetectURL = "https://aip.baidubce.com/rest/2.0/face/v3/detect"
+)
+
+// Baidu error codes telling that the access token must be renewed.
+const (
+	baiduTokenInvalid = 110
+	baiduTokenExpired = 111
+)
+
+// defaultRefreshBefore is how long before its expiry a token is replaced.
+// Baidu tokens live for 30 days; a token living less than twice as long is
+// replaced halfway through its lifetime instead.
+const defaultRefreshBefore = 24 * time.Hour
+
+// Defaults of BaiduToken.Timeout and BaiduToken.RetryDelay.
+const (
+	defaultTokenTimeout = 30 * time.Second
+	defaultRetryDelay   = time.Minute
+)
+
+// BaiduToken obtains access tokens for the Baidu AI APIs from an API key and
+// secret key with the OAuth client credentials grant. Tokens are cached in
+// memory and, if CacheFile is set, on disk so that restarts reuse them. A
+// BaiduToken is safe for concurrent use; concurrent callers share a single
+// token request.
+type BaiduToken struct {
+	APIKey    string
+	SecretKey string
+
+	TokenURL      string        // empty means BaiduTokenURL
+	CacheFile     string        // empty disables the disk cache
+	RefreshBefore time.Duration // renew this long before expiry, but at most halfway through; 0 means one day
+	Timeout       time.Duration // bounds each token request; 0 means 30 seconds
+	RetryDelay    time.Duration // wait after a failed renewal before the next; 0 means one minute
+	Client        *http.Client  // nil means transport.Default()
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+	expiry time.Time
+	failed time.Time    // when the last token request failed
+	flight *tokenFlight // token request in progress, nil for none
+}
+
+// tokenFlight is a token request that callers wait for.
+type tokenFlight struct {
+	done  chan struct{} // closed when token and err are set
+	token string
+	err   error
+}
+
+// cachedToken is the on-disk form of a token. The API key is kept to detect
+// a cache file written for another application.
+type cachedToken struct {
+	APIKey string    `json:"api_key"`
+	Token  string    `json:"access_token"`
+	Issued time.Time `json:"issued"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// tokenResponse is the answer of the OAuth endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"` // seconds
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Token returns a valid access token, fetching a new one when none is
+// cached or the cached one is about to expire. When renewing a token that
+// has not expired yet fails, the old token is returned and the renewal is
+// not tried again for RetryDelay.
+func (t *BaiduToken) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	if t.token == "" {
+		t.loadCache()
+	}
+	now := time.Now()
+	if t.token != "" && (now.Before(t.renewAt()) || t.backingOff(now)) {
+		token := t.token
+		t.mu.Unlock()
+		return token, nil
+	}
+	f := t.flight
+	if f == nil {
+		// the request runs without the lock; callers that need a new token
+		// wait for it, each until its own ctx ends
+		f = &tokenFlight{done: make(chan struct{})}
+		t.flight = f
+		go t.fetch(f)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.token, f.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fetch runs the token request of f and publishes its outcome. The request
+// is not bound to the context of the caller that started it, since others
+// may be waiting for it; Timeout bounds it instead.
+func (t *BaiduToken) fetch(f *tokenFlight) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = defaultTokenTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	token, lifetime, err := t.request(ctx)
+	cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		t.failed = now
+	}
+	switch {
+	case err == nil:
+		t.token, t.issued, t.expiry = token, now, now.Add(lifetime)
+		t.failed = time.Time{}
+		t.saveCache()
+		f.token = token
+	case t.token != "" && now.Before(t.expiry):
+		f.token = t.token
+	default:
+		f.err = err
+	}
+	t.flight = nil
+	close(f.done)
+}
+
+// Invalidate drops token if it is still the current one, so that the next
+// call to Token fetches a new one. The API answers error_code 110 or 111
+// for tokens it no longer accepts.
+func (t *BaiduToken) Invalidate(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == token {
+		t.token = ""
+		t.expiry = time.Time{}
+		if t.CacheFile != "" {
+			os.Remove(t.CacheFile)
+		}
+	}
+}
+
+// renewAt returns when the current token is replaced: RefreshBefore ahead
+// of its expiry, but not before half of its lifetime has passed, so that
+// short-lived tokens are not fetched again on every call. t.mu must be held.
+func (t *BaiduToken) renewAt() time.Time {
+	margin := defaultRefreshBefore
+	if t.RefreshBefore > 0 {
+		margin = t.RefreshBefore
+	}
+	if half := t.expiry.Sub(t.issued) / 2; half < margin {
+		margin = half
+	}
+	return t.expiry.Add(-margin)
+}
+
+// backingOff reports whether a renewal failed less than RetryDelay ago
+// while the current token is still valid. t.mu must be held.
+func (t *BaiduToken) backingOff(now time.Time) bool {
+	delay := t.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	return now.Before(t.expiry) && now.Before(t.failed.Add(delay))
+}
+
+// request asks the OAuth endpoint for a new token and returns it with its
+// lifetime.
+func (t *BaiduToken) request(ctx context.Context) (string, time.Duration, error) {
+	if t.APIKey == "" || t.SecretKey == "" {
+		return "", 0, &Error{Backend: SourceBaidu, Kind: ErrAuth, Msg: "API key and secret key are required for a token"}
+	}
+
+	endpoint := t.TokenURL
+	if endpoint == "" {
+		endpoint = BaiduTokenURL
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.APIKey},
+		"client_secret": {t.SecretKey},
+	}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, &Error{Backend: SourceBaidu, Kind: ErrTransport, Msg: "build token request", Err: stripURL(err)}
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentForm)
+
+	client := t.Client
+	if client == nil {
+		client = transport.Default()
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", 0, &Error{Backend: SourceBaidu, Kind: ErrTransport, Msg: "token request", Err: stripURL(err)}
+	}
+	defer res.Body.Close()
+
+	// the endpoint answers refused grants with 400 or 401 and a JSON body
+	var tr tokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&tr); err != nil {
+		if serr := statusError(SourceBaidu, res); serr != nil {
+			return "", 0, serr
+		}
+		return "", 0, &Error{Backend: SourceBaidu, Kind: ErrMalformed, StatusCode: res.StatusCode, Msg: "token response", Err: err}
+	}
+	if tr.Error != "" || tr.AccessToken == "" {
+		return "", 0, &Error{Backend: SourceBaidu, Kind: ErrAuth, StatusCode: res.StatusCode, Msg: strings.TrimSpace("token refused: " + tr.Error + " " + tr.Description)}
+	}
+
+	return tr.AccessToken, time.Duration(tr.ExpiresIn) * time.Second, nil
+}
+
+// loadCache reads the token from CacheFile. A missing, unreadable or foreign
+// cache is ignored. t.mu must be held.
+func (t *BaiduToken) loadCache() {
+	if t.CacheFile == "" {
+		return
+	}
+	buf, err := os.ReadFile(t.CacheFile)
+	if err != nil {
+		return
+	}
+	var c cachedToken
+	if err := json.Unmarshal(buf, &c); err != nil || c.APIKey != t.APIKey {
+		return
+	}
+	t.token, t.issued, t.expiry = c.Token, c.Issued, c.Expiry
+}
+
+// saveCache writes the token to CacheFile, readable by the owner only.
+// Failures only cost a token request on the next start. t.mu must be held.
+func (t *BaiduToken) saveCache() {
+	if t.CacheFile == "" {
+		return
+	}
+	buf, err := json.Marshal(cachedToken{APIKey: t.APIKey, Token: t.token, Issued: t.issued, Expiry: t.expiry})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(t.CacheFile), 0700); err != nil {
+		return
+	}
+	tmp := t.CacheFile + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, t.CacheFile)
+}
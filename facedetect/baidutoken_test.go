@@ -0,0 +1,341 @@
+package facedetect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// tokenServer hands out the tokens "token-1", "token-2", ... living expiresIn
+// seconds and counts the requests.
+type tokenServer struct {
+	*httptest.Server
+	expiresIn int64
+	requests  int64
+	failing   int32         // if set, requests fail with HTTP 500
+	release   chan struct{} // if set, requests wait for it
+}
+
+func newTokenServer(t *testing.T, expiresIn int64) *tokenServer {
+	s := &tokenServer{expiresIn: expiresIn}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("client_id") != "ak" || r.FormValue("client_secret") != "sk" {
+			t.Errorf("unexpected token request %s %s", r.Method, r.Form.Encode())
+		}
+		n := atomic.AddInt64(&s.requests, 1)
+		if atomic.LoadInt32(&s.failing) != 0 {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		if s.release != nil {
+			<-s.release
+		}
+		json.NewEncoder(w).Encode(tokenResponse{AccessToken: fmt.Sprintf("token-%d", n), ExpiresIn: s.expiresIn})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) token() *BaiduToken {
+	return &BaiduToken{APIKey: "ak", SecretKey: "sk", TokenURL: s.URL}
+}
+
+func TestBaiduTokenCached(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	tok := s.token()
+	for i := 0; i < 5; i++ {
+		got, err := tok.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "token-1" {
+			t.Fatalf("call %d: got %q, want token-1", i, got)
+		}
+	}
+	if n := atomic.LoadInt64(&s.requests); n != 1 {
+		t.Fatalf("%d token requests, want 1", n)
+	}
+}
+
+func TestBaiduTokenExpiry(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	tok := s.token()
+	if _, err := tok.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// within RefreshBefore of the expiry the token is renewed
+	tok.mu.Lock()
+	tok.issued = time.Now().Add(-29 * 24 * time.Hour)
+	tok.expiry = time.Now().Add(time.Hour)
+	tok.mu.Unlock()
+	if got, _ := tok.Token(context.Background()); got != "token-2" {
+		t.Fatalf("got %q near expiry, want the renewed token-2", got)
+	}
+}
+
+func TestBaiduTokenShortLifetime(t *testing.T) {
+	// a token living less than RefreshBefore is kept for half its lifetime
+	// instead of being fetched again on every call
+	s := newTokenServer(t, 3600)
+	tok := s.token()
+	for i := 0; i < 3; i++ {
+		if got, err := tok.Token(context.Background()); err != nil || got != "token-1" {
+			t.Fatalf("call %d: got %q, %v, want token-1", i, got, err)
+		}
+	}
+
+	tok.mu.Lock()
+	tok.issued = time.Now().Add(-31 * time.Minute)
+	tok.expiry = time.Now().Add(29 * time.Minute)
+	tok.mu.Unlock()
+	if got, _ := tok.Token(context.Background()); got != "token-2" {
+		t.Fatalf("got %q past half the lifetime, want token-2", got)
+	}
+}
+
+func TestBaiduTokenConcurrent(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	s.release = make(chan struct{})
+	tok := s.token()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := tok.Token(context.Background()); err != nil || got != "token-1" {
+				t.Errorf("got %q, %v, want token-1", got, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+	if n := atomic.LoadInt64(&s.requests); n != 1 {
+		t.Fatalf("%d token requests for concurrent callers, want 1", n)
+	}
+}
+
+func TestBaiduTokenCancel(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	s.release = make(chan struct{})
+	defer close(s.release)
+	tok := s.token()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tok.Token(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v while the token request hangs, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBaiduTokenHang(t *testing.T) {
+	// the first request is never answered, the second one is
+	var requests int64
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			<-release
+			return
+		}
+		json.NewEncoder(w).Encode(tokenResponse{AccessToken: "token-2", ExpiresIn: 3600})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	tok := &BaiduToken{APIKey: "ak", SecretKey: "sk", TokenURL: srv.URL, Timeout: 50 * time.Millisecond}
+	if _, err := tok.Token(context.Background()); !errors.Is(err, ErrTransport) {
+		t.Fatalf("got %v from a hanging token request, want a %s error", err, ErrTransport)
+	}
+	if got, err := tok.Token(context.Background()); err != nil || got != "token-2" {
+		t.Fatalf("got %q, %v after the timeout, want token-2", got, err)
+	}
+}
+
+func TestBaiduTokenRenewalBackoff(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	tok := s.token()
+	tok.RetryDelay = time.Hour
+	if _, err := tok.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the renewal fails while the token is still valid: the old token is
+	// kept and the renewal not tried again before RetryDelay
+	atomic.StoreInt32(&s.failing, 1)
+	tok.mu.Lock()
+	tok.issued = time.Now().Add(-29 * 24 * time.Hour)
+	tok.expiry = time.Now().Add(time.Hour)
+	tok.mu.Unlock()
+	for i := 0; i < 5; i++ {
+		if got, err := tok.Token(context.Background()); err != nil || got != "token-1" {
+			t.Fatalf("call %d: got %q, %v, want the old token-1", i, got, err)
+		}
+	}
+	if n := atomic.LoadInt64(&s.requests); n != 2 {
+		t.Fatalf("%d token requests, want 2", n)
+	}
+
+	atomic.StoreInt32(&s.failing, 0)
+	tok.mu.Lock()
+	tok.failed = time.Now().Add(-2 * time.Hour)
+	tok.mu.Unlock()
+	if got, _ := tok.Token(context.Background()); got != "token-3" {
+		t.Fatalf("got %q after RetryDelay, want the renewed token-3", got)
+	}
+}
+
+func TestBaiduTokenCache(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	file := filepath.Join(t.TempDir(), "cache", "token.json")
+	tok := s.token()
+	tok.CacheFile = file
+	if got, err := tok.Token(context.Background()); err != nil || got != "token-1" {
+		t.Fatalf("got %q, %v, want token-1", got, err)
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("cache file mode %v, want 0600", fi.Mode().Perm())
+	}
+
+	// a restart reuses the cached token with its issue time
+	restarted := s.token()
+	restarted.CacheFile = file
+	if got, err := restarted.Token(context.Background()); err != nil || got != "token-1" {
+		t.Fatalf("got %q, %v from the cache, want token-1", got, err)
+	}
+	if n := atomic.LoadInt64(&s.requests); n != 1 {
+		t.Fatalf("%d token requests, want 1", n)
+	}
+	tok.mu.Lock()
+	issued := tok.issued
+	tok.mu.Unlock()
+	if !restarted.issued.Equal(issued) {
+		t.Fatalf("cached issue time %v, want %v", restarted.issued, issued)
+	}
+}
+
+func TestBaiduTokenCacheIgnored(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		cache cachedToken
+	}{
+		{"other API key", cachedToken{APIKey: "other", Token: "cached", Issued: now, Expiry: now.Add(30 * 24 * time.Hour)}},
+		{"expired", cachedToken{APIKey: "ak", Token: "cached", Issued: now.Add(-31 * 24 * time.Hour), Expiry: now.Add(-24 * time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTokenServer(t, 30*24*3600)
+			file := filepath.Join(t.TempDir(), "token.json")
+			buf, _ := json.Marshal(tt.cache)
+			if err := os.WriteFile(file, buf, 0600); err != nil {
+				t.Fatal(err)
+			}
+			tok := s.token()
+			tok.CacheFile = file
+			if got, err := tok.Token(context.Background()); err != nil || got != "token-1" {
+				t.Fatalf("got %q, %v, want a new token-1", got, err)
+			}
+			// the new token replaces the cached one
+			buf, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c cachedToken
+			if err := json.Unmarshal(buf, &c); err != nil || c.APIKey != "ak" || c.Token != "token-1" {
+				t.Fatalf("cache holds %s, want token-1 of ak", buf)
+			}
+		})
+	}
+}
+
+func TestBaiduTokenRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(tokenResponse{Error: "invalid_client", Description: "unknown client id"})
+	}))
+	defer srv.Close()
+
+	tok := &BaiduToken{APIKey: "ak", SecretKey: "sk", TokenURL: srv.URL}
+	if _, err := tok.Token(context.Background()); !errors.Is(err, ErrAuth) {
+		t.Fatalf("got %v, want an %s error", err, ErrAuth)
+	}
+}
+
+// TestBaiduTokenRejected checks that error codes 110 and 111 replace the
+// token and repeat the request once.
+func TestBaiduTokenRejected(t *testing.T) {
+	for _, code := range []int{baiduTokenInvalid, baiduTokenExpired} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			s := newTokenServer(t, 30*24*3600)
+			var mu sync.Mutex
+			var calls []string
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token := r.URL.Query().Get("access_token")
+				mu.Lock()
+				calls = append(calls, token)
+				mu.Unlock()
+				if token == "token-1" {
+					json.NewEncoder(w).Encode(baiduResponse{ErrorCode: code, ErrorMsg: "Access token invalid or no longer valid"})
+					return
+				}
+				fmt.Fprint(w, `{"error_code":0,"result":{"face_list":[{"location":{"left":1,"top":2,"width":3,"height":4},"face_probability":0.9}]}}`)
+			}))
+			defer api.Close()
+
+			b := &Baidu{URL: api.URL, Token: s.token()}
+			img := gocv.NewMatWithSize(1, 1, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			faces, err := b.Detect(context.Background(), img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(faces) != 1 {
+				t.Fatalf("got %d faces, want 1", len(faces))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(calls) != 2 || calls[0] != "token-1" || calls[1] != "token-2" {
+				t.Fatalf("detect called with tokens %q, want token-1 then token-2", calls)
+			}
+		})
+	}
+}
+
+// TestBaiduTokenRejectedTwice checks that a token rejected again is reported
+// instead of retried without end.
+func TestBaiduTokenRejectedTwice(t *testing.T) {
+	s := newTokenServer(t, 30*24*3600)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(baiduResponse{ErrorCode: baiduTokenExpired, ErrorMsg: "Access token expired"})
+	}))
+	defer api.Close()
+
+	b := &Baidu{URL: api.URL, Token: s.token()}
+	img := gocv.NewMatWithSize(1, 1, gocv.MatTypeCV8UC3)
+	defer img.Close()
+	_, err := b.Detect(context.Background(), img)
+	if e, ok := err.(*Error); !ok || e.Code != baiduTokenExpired {
+		t.Fatalf("got %v, want error code %d", err, baiduTokenExpired)
+	}
+	if n := atomic.LoadInt64(&s.requests); n != 2 {
+		t.Fatalf("%d token requests, want 2", n)
+	}
+}
@@ -0,0 +1,216 @@
+package facedetect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrClosed is returned by a Batcher for frames submitted after Close.
+var ErrClosed = errors.New("detector closed")
+
+// Batching configures a Batcher.
+type Batching struct {
+	Size    int           `yaml:"size"`     // images per forward pass, 0 or 1 runs every frame alone
+	MaxWait time.Duration `yaml:"max_wait"` // longest the first frame of a batch waits for it to fill
+}
+
+// DefaultBatching holds the wait used when none is configured; it is short
+// next to a forward pass of the bundled network on a CPU.
+var DefaultBatching = Batching{MaxWait: 10 * time.Millisecond}
+
+// withDefaults returns b with its zero fields taken from DefaultBatching.
+func (b Batching) withDefaults() Batching {
+	if b.MaxWait == 0 {
+		b.MaxWait = DefaultBatching.MaxWait
+	}
+	return b
+}
+
+// Check reports settings a Batcher cannot use.
+func (b Batching) Check() error {
+	if b.Size < 0 || b.MaxWait < 0 {
+		return fmt.Errorf("batch size and wait must not be negative")
+	}
+	return nil
+}
+
+// Batcher collects the frames of concurrent Detect calls, from one or more
+// sources, into batches and runs each batch through a Local detector with a
+// single forward pass. A batch is run once it holds Size images or its
+// first frame waited MaxWait, whichever comes first. A frame and its tiles
+// always go into the same batch, even if that makes it exceed Size.
+//
+// Batching trades latency for throughput: the network spends less time per
+// image in a large blob, but a frame may wait for others to arrive. Callers
+// need at least as many frames in flight as images per batch for it to fill.
+type Batcher struct {
+	l   *Local
+	cfg Batching
+
+	reqs chan *batchRequest
+	quit chan struct{} // closed by Close
+	done chan struct{} // closed when run returns
+	once sync.Once
+
+	passes, images int64
+}
+
+// batchRequest is one frame waiting for its batch.
+type batchRequest struct {
+	ctx   context.Context
+	imgs  []gocv.Mat // the passes of the frame
+	found [][]Face   // detections per pass, set before done is closed
+	err   error
+	done  chan struct{}
+}
+
+// NewBatcher starts a scheduler for l. The Batcher takes over l; closing the
+// Batcher closes it.
+func NewBatcher(l *Local, cfg Batching) *Batcher {
+	cfg = cfg.withDefaults()
+	if cfg.Size < 1 {
+		cfg.Size = 1
+	}
+	b := &Batcher{
+		l:    l,
+		cfg:  cfg,
+		reqs: make(chan *batchRequest),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Detect implements Detector.
+func (b *Batcher) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	passes, origins := b.l.passes(img)
+	defer closeTiles(passes)
+
+	req := &batchRequest{ctx: ctx, imgs: passes, done: make(chan struct{})}
+	select {
+	case b.reqs <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.quit:
+		return nil, ErrClosed
+	}
+	// the scheduler reads the images until it answers, so wait for it even
+	// when ctx is done; it skips frames cancelled before their batch runs
+	<-req.done
+	if req.err != nil {
+		return nil, req.err
+	}
+	return b.l.merge(req.found, origins, img.Cols(), img.Rows()), nil
+}
+
+// run forms the batches until Close. Every request it receives is answered.
+func (b *Batcher) run() {
+	defer close(b.done)
+	var next *batchRequest // received but left for the following batch
+	for {
+		if next == nil {
+			select {
+			case next = <-b.reqs:
+			case <-b.quit:
+				return
+			}
+		}
+		batch := []*batchRequest{next}
+		n := len(next.imgs)
+		next = nil
+
+		timer := time.NewTimer(b.cfg.MaxWait)
+	collect:
+		for n < b.cfg.Size {
+			select {
+			case r := <-b.reqs:
+				if n+len(r.imgs) > b.cfg.Size {
+					next = r
+					break collect
+				}
+				batch = append(batch, r)
+				n += len(r.imgs)
+			case <-timer.C:
+				break collect
+			case <-b.quit:
+				break collect
+			}
+		}
+		timer.Stop()
+		b.forward(batch)
+	}
+}
+
+// forward runs one batch and hands each request its detections.
+func (b *Batcher) forward(batch []*batchRequest) {
+	var imgs []gocv.Mat
+	live := batch[:0]
+	for _, r := range batch {
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+			close(r.done)
+			continue
+		}
+		live = append(live, r)
+		imgs = append(imgs, r.imgs...)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	found, err := b.l.forward(imgs)
+	atomic.AddInt64(&b.passes, 1)
+	atomic.AddInt64(&b.images, int64(len(imgs)))
+	for _, r := range live {
+		if err != nil {
+			r.err = err
+		} else {
+			r.found, found = found[:len(r.imgs)], found[len(r.imgs):]
+		}
+		close(r.done)
+	}
+}
+
+// BatchStats counts the forward passes of a Batcher.
+type BatchStats struct {
+	Passes int64 // forward passes run
+	Images int64 // frames and tiles detected by them
+}
+
+func (s BatchStats) String() string {
+	per := 0.0
+	if s.Passes > 0 {
+		per = float64(s.Images) / float64(s.Passes)
+	}
+	return fmt.Sprintf("%d forward passes, %.2f images per pass", s.Passes, per)
+}
+
+// Stats returns a snapshot of the counters.
+func (b *Batcher) Stats() BatchStats {
+	return BatchStats{
+		Passes: atomic.LoadInt64(&b.passes),
+		Images: atomic.LoadInt64(&b.images),
+	}
+}
+
+// Close stops the scheduler once the batch being formed has run, fails
+// later frames with ErrClosed and closes the Local detector.
+func (b *Batcher) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.quit)
+		<-b.done
+		err = b.l.Close()
+	})
+	return err
+}
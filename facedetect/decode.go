@@ -0,0 +1,229 @@
+package facedetect
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// layout reads the output blobs of one kind of network.
+type layout struct {
+	// outputs are the output layers of the reference export, in the order
+	// decode takes them; nil reads the last layer only
+	outputs []string
+	// batched is set when the first dimension of every output blob is the
+	// image of the batch; SSD outputs instead tag each detection with it
+	batched bool
+	// decode gets one blob per output layer, holding the outputs of every
+	// image of the batch, and returns the detections reaching threshold
+	// for each image in pixels of its size in sizes, tagged with source
+	decode func(outs []gocv.Mat, sizes []image.Point, m *Manifest, source string, threshold float64) ([][]Face, error)
+}
+
+// layouts maps the Layout* names to their decoders.
+var layouts = map[string]layout{
+	LayoutSSD: {
+		decode: decodeSSD,
+	},
+	LayoutUltraFace: {
+		outputs: []string{"scores", "boxes"},
+		batched: true,
+		decode:  decodeUltraFace,
+	},
+	LayoutYuNet: {
+		outputs: []string{
+			"cls_8", "cls_16", "cls_32",
+			"obj_8", "obj_16", "obj_32",
+			"bbox_8", "bbox_16", "bbox_32",
+			"kps_8", "kps_16", "kps_32",
+		},
+		batched: true,
+		decode:  decodeYuNet,
+	},
+}
+
+// decode returns the detections in the outputs of a forward pass over
+// images of sizes with the layout of m. It fails if an output does not hold
+// one entry per image.
+func decode(outs []gocv.Mat, sizes []image.Point, m *Manifest, source string, threshold float64) ([][]Face, error) {
+	l := layouts[m.Output.Layout]
+	if l.batched {
+		for i, out := range outs {
+			if size := out.Size(); len(size) == 0 || size[0] != len(sizes) {
+				return nil, fmt.Errorf("%s: output %d has shape %v, want %d images first", m.Name, i, size, len(sizes))
+			}
+		}
+	}
+	return l.decode(outs, sizes, m, source, threshold)
+}
+
+func decodeSSD(outs []gocv.Mat, sizes []image.Point, m *Manifest, source string, threshold float64) ([][]Face, error) {
+	return performDetection(outs[0], sizes, m.Labels, source, threshold), nil
+}
+
+// faceLabel returns the label of the face class of a single class network,
+// class 1 after the background like in the SSD label maps. It returns false
+// when Labels leaves it out.
+func faceLabel(m *Manifest) (string, bool) {
+	label, ok := m.Labels[1]
+	return label, ok || len(m.Labels) == 0
+}
+
+// decodeUltraFace reads the scores [B, N, 2] and boxes [B, N, 4] of the
+// Ultra-Light-Fast-Generic-Face-Detector (RFB-320 and slim). The scores are
+// the softmax of background and face; the boxes are corners relative to
+// the input, or with Output.Priors offsets from the priors.
+func decodeUltraFace(outs []gocv.Mat, sizes []image.Point, m *Manifest, source string, threshold float64) ([][]Face, error) {
+	scores, boxes := outs[0], outs[1]
+	n := scores.Total() / 2 / len(sizes)
+	if boxes.Total() != n*4*len(sizes) {
+		return nil, fmt.Errorf("%s: %d scores but %d box values", m.Name, scores.Total(), boxes.Total())
+	}
+	var priors [][4]float64
+	if m.Output.Priors {
+		priors = ultraFacePriors(m.Input.Width, m.Input.Height)
+		if len(priors) != n {
+			return nil, fmt.Errorf("%s: %d boxes, but a %dx%d input has %d priors",
+				m.Name, n, m.Input.Width, m.Input.Height, len(priors))
+		}
+	}
+
+	faces := make([][]Face, len(sizes))
+	label, ok := faceLabel(m)
+	if !ok {
+		return faces, nil
+	}
+	for b, size := range sizes {
+		for i := 0; i < n; i++ {
+			confidence := float64(scores.GetFloatAt(b, i*2+1))
+			if confidence < threshold {
+				continue
+			}
+			var box [4]float64
+			for k := range box {
+				box[k] = float64(boxes.GetFloatAt(b, i*4+k))
+			}
+			if priors != nil {
+				box = fromPrior(box, priors[i])
+			}
+			faces[b] = append(faces[b], Face{
+				Box: image.Rect(int(box[0]*float64(size.X)), int(box[1]*float64(size.Y)),
+					int(box[2]*float64(size.X)), int(box[3]*float64(size.Y))),
+				Confidence: confidence,
+				Source:     source,
+				Label:      label,
+			})
+		}
+	}
+	return faces, nil
+}
+
+// UltraFace anchors: the feature map strides in input pixels and the sides
+// of the square priors centered on each cell of that map.
+var (
+	ultraFaceStrides  = []int{8, 16, 32, 64}
+	ultraFaceMinBoxes = [][]float64{{10, 16, 24}, {32, 48}, {64, 96}, {128, 192, 256}}
+)
+
+// ultraFacePriors returns the priors of a width x height input as center x,
+// center y, width and height relative to the input, in the order of the
+// network's outputs.
+func ultraFacePriors(width, height int) [][4]float64 {
+	var priors [][4]float64
+	for s, stride := range ultraFaceStrides {
+		cols := (width + stride - 1) / stride
+		rows := (height + stride - 1) / stride
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				cx := clamp01((float64(x) + 0.5) * float64(stride) / float64(width))
+				cy := clamp01((float64(y) + 0.5) * float64(stride) / float64(height))
+				for _, side := range ultraFaceMinBoxes[s] {
+					priors = append(priors, [4]float64{cx, cy,
+						clamp01(side / float64(width)), clamp01(side / float64(height))})
+				}
+			}
+		}
+	}
+	return priors
+}
+
+// fromPrior decodes the offsets of a box from its prior with the usual SSD
+// variances, 0.1 for the center and 0.2 for the size, into corners.
+func fromPrior(loc, prior [4]float64) [4]float64 {
+	cx := prior[0] + loc[0]*0.1*prior[2]
+	cy := prior[1] + loc[1]*0.1*prior[3]
+	w := prior[2] * math.Exp(loc[2]*0.2)
+	h := prior[3] * math.Exp(loc[3]*0.2)
+	return [4]float64{cx - w/2, cy - h/2, cx + w/2, cy + h/2}
+}
+
+// yuNetStrides are the feature map strides of YuNet in input pixels.
+var yuNetStrides = []int{8, 16, 32}
+
+// decodeYuNet reads the anchor-free outputs of YuNet (OpenCV Zoo's
+// face_detection_yunet_2023mar): per stride the class and objectness scores
+// [B, N, 1], the box [B, N, 4] as the cell offset of its center and the log
+// of its size in strides, and five landmarks [B, N, 10] as cell offsets.
+// The landmarks are the eyes, the nose tip and the mouth corners.
+func decodeYuNet(outs []gocv.Mat, sizes []image.Point, m *Manifest, source string, threshold float64) ([][]Face, error) {
+	faces := make([][]Face, len(sizes))
+	label, ok := faceLabel(m)
+	if !ok {
+		return faces, nil
+	}
+	w, h := m.Input.Width, m.Input.Height
+	for s, stride := range yuNetStrides {
+		cls, obj, bbox, kps := outs[s], outs[s+3], outs[s+6], outs[s+9]
+		cols, rows := w/stride, h/stride
+		n := cols * rows
+		if cls.Total() != n*len(sizes) || obj.Total() != n*len(sizes) ||
+			bbox.Total() != n*4*len(sizes) || kps.Total() != n*10*len(sizes) {
+			return nil, fmt.Errorf("%s: outputs of stride %d do not fit a %dx%d input", m.Name, stride, w, h)
+		}
+		st := float64(stride)
+		for b, size := range sizes {
+			sx, sy := float64(size.X)/float64(w), float64(size.Y)/float64(h)
+			for r := 0; r < rows; r++ {
+				for c := 0; c < cols; c++ {
+					i := r*cols + c
+					confidence := math.Sqrt(clamp01(float64(cls.GetFloatAt(b, i))) * clamp01(float64(obj.GetFloatAt(b, i))))
+					if confidence < threshold {
+						continue
+					}
+					cx := (float64(c) + float64(bbox.GetFloatAt(b, i*4))) * st
+					cy := (float64(r) + float64(bbox.GetFloatAt(b, i*4+1))) * st
+					bw := math.Exp(float64(bbox.GetFloatAt(b, i*4+2))) * st
+					bh := math.Exp(float64(bbox.GetFloatAt(b, i*4+3))) * st
+					landmarks := make([]Landmark, 5)
+					for k := range landmarks {
+						landmarks[k] = Landmark{
+							X: (float64(c) + float64(kps.GetFloatAt(b, i*10+2*k))) * st * sx,
+							Y: (float64(r) + float64(kps.GetFloatAt(b, i*10+2*k+1))) * st * sy,
+						}
+					}
+					faces[b] = append(faces[b], Face{
+						Box: image.Rect(int((cx-bw/2)*sx), int((cy-bh/2)*sy),
+							int((cx+bw/2)*sx), int((cy+bh/2)*sy)),
+						Confidence: confidence,
+						Source:     source,
+						Label:      label,
+						Attributes: &Attributes{Landmarks: landmarks},
+					})
+				}
+			}
+		}
+	}
+	return faces, nil
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package facedetect
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// blob returns a float blob of the given shape with values set at the
+// (image, offset) positions of at.
+func blob(shape []int, at map[[2]int]float32) gocv.Mat {
+	m := gocv.NewMatWithSizes(shape, gocv.MatTypeCV32F)
+	for pos, v := range at {
+		m.SetFloatAt(pos[0], pos[1], v)
+	}
+	return m
+}
+
+func ultraFaceManifest(priors bool) *Manifest {
+	return &Manifest{
+		Name:   "ultraface",
+		Input:  InputSpec{Width: 32, Height: 32},
+		Output: OutputSpec{Layout: LayoutUltraFace, Priors: priors},
+		Labels: map[int]string{0: "background", 1: "face"},
+	}
+}
+
+func TestDecodeUltraFace(t *testing.T) {
+	// three anchors for each of two images; scores are background, face
+	scores := blob([]int{2, 3, 2}, map[[2]int]float32{
+		{0, 1*2 + 1}: 0.75,
+		{1, 0*2 + 1}: 0.625,
+		{1, 2*2 + 1}: 0.25, // below the threshold
+	})
+	boxes := blob([]int{2, 3, 4}, map[[2]int]float32{
+		{0, 1*4 + 0}: 0.25, {0, 1*4 + 1}: 0.5, {0, 1*4 + 2}: 0.5, {0, 1*4 + 3}: 0.75,
+		{1, 0*4 + 2}: 1, {1, 0*4 + 3}: 1,
+		{1, 2*4 + 2}: 1, {1, 2*4 + 3}: 1,
+	})
+	sizes := []image.Point{{100, 40}, {200, 100}}
+
+	got, err := decode([]gocv.Mat{scores, boxes}, sizes, ultraFaceManifest(false), SourceONNX, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]Face{
+		{{Box: image.Rect(25, 20, 50, 30), Confidence: 0.75, Source: SourceONNX, Label: "face"}},
+		{{Box: image.Rect(0, 0, 200, 100), Confidence: 0.625, Source: SourceONNX, Label: "face"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeUltraFacePriors(t *testing.T) {
+	// a 32x32 input has 4x4 cells of three priors at stride 8, 2x2 of two
+	// at 16, one of two at 32 and one of three at 64
+	const n = 61
+	scores := blob([]int{1, n, 2}, map[[2]int]float32{{0, 1}: 0.75})
+	boxes := blob([]int{1, n, 4}, nil)
+	got, err := decode([]gocv.Mat{scores, boxes}, []image.Point{{320, 320}}, ultraFaceManifest(true), SourceONNX, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// zero offsets give the first prior: centered on the first cell, 10
+	// input pixels wide, scaled by 10 to the image
+	if len(got[0]) != 1 || got[0][0].Box != image.Rect(-10, -10, 90, 90) {
+		t.Fatalf("got %+v, want the box of the first prior", got)
+	}
+
+	short := blob([]int{1, n - 1, 2}, nil)
+	shortBoxes := blob([]int{1, n - 1, 4}, nil)
+	if _, err := decode([]gocv.Mat{short, shortBoxes}, []image.Point{{320, 320}}, ultraFaceManifest(true), SourceONNX, 0.5); err == nil {
+		t.Fatal("decoded fewer boxes than priors")
+	}
+}
+
+// yuNetOutputs returns empty outputs of a 32x32 YuNet for one image, with
+// the outputs of stride 8 taken from stride8 if given.
+func yuNetOutputs(stride8 map[string]gocv.Mat) []gocv.Mat {
+	var outs []gocv.Mat
+	for _, kind := range []struct {
+		name  string
+		width int
+	}{{"cls", 1}, {"obj", 1}, {"bbox", 4}, {"kps", 10}} {
+		for _, cells := range []int{16, 4, 1} {
+			if m, ok := stride8[kind.name]; ok && cells == 16 {
+				outs = append(outs, m)
+				continue
+			}
+			outs = append(outs, blob([]int{1, cells, kind.width}, nil))
+		}
+	}
+	return outs
+}
+
+func TestDecodeYuNet(t *testing.T) {
+	// a face in the cell at row 1, column 2 of the stride 8 map
+	const i = 1*4 + 2
+	outs := yuNetOutputs(map[string]gocv.Mat{
+		"cls":  blob([]int{1, 16, 1}, map[[2]int]float32{{0, i}: 1}),
+		"obj":  blob([]int{1, 16, 1}, map[[2]int]float32{{0, i}: 0.5625}),
+		"bbox": blob([]int{1, 16, 4}, map[[2]int]float32{{0, i * 4}: 0.5, {0, i*4 + 1}: 0.5}),
+		"kps":  blob([]int{1, 16, 10}, map[[2]int]float32{{0, i * 10}: 0.5, {0, i*10 + 1}: 0.25}),
+	})
+	m := &Manifest{Name: "yunet", Input: InputSpec{Width: 32, Height: 32}, Output: OutputSpec{Layout: LayoutYuNet}}
+
+	got, err := decode(outs, []image.Point{{64, 32}}, m, SourceONNX, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0]) != 1 {
+		t.Fatalf("got %+v, want one face", got)
+	}
+	f := got[0][0]
+	// the center is half a cell into it and the box one stride wide, in an
+	// image twice as wide as the input
+	if f.Box != image.Rect(32, 8, 48, 16) || f.Confidence != 0.75 || f.Source != SourceONNX {
+		t.Fatalf("got %+v", f)
+	}
+	lm := f.Attributes.Landmarks
+	if len(lm) != 5 || lm[0] != (Landmark{X: 40, Y: 10}) || lm[1] != (Landmark{X: 32, Y: 8}) {
+		t.Fatalf("got landmarks %+v", lm)
+	}
+}
+
+func TestDecodeBatchSize(t *testing.T) {
+	sizes := []image.Point{{100, 100}, {100, 100}}
+	tests := []struct {
+		name string
+		outs []gocv.Mat
+		m    *Manifest
+	}{
+		{"ultraface", []gocv.Mat{blob([]int{1, 6, 2}, nil), blob([]int{1, 6, 4}, nil)}, ultraFaceManifest(false)},
+		{"yunet", yuNetOutputs(nil), &Manifest{Input: InputSpec{Width: 32, Height: 32}, Output: OutputSpec{Layout: LayoutYuNet}}},
+	}
+	for _, tt := range tests {
+		// the outputs hold as many values as two images would, but for one
+		if _, err := decode(tt.outs, sizes, tt.m, SourceONNX, 0.5); err == nil {
+			t.Errorf("%s: decoded outputs of one image for two", tt.name)
+		}
+	}
+
+	// SSD outputs tag each detection with its image instead
+	ssd := blob([]int{1, 1, 2, 7}, nil)
+	ssd.SetFloatAt(0, 7, 1)
+	ssd.SetFloatAt(0, 9, 1)
+	got, err := decode([]gocv.Mat{ssd}, sizes, &Manifest{Output: OutputSpec{Layout: LayoutSSD}}, SourceCaffe, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got[0]) != 0 || len(got[1]) != 1 {
+		t.Fatalf("got %+v, want one face in the second image", got)
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	for model, want := range map[string]string{
+		"res10_300x300_ssd_iter_140000.caffemodel": SourceCaffe,
+		"opencv_face_detector_uint8.pb":            SourceTensorFlow,
+		"face_detection_yunet_2023mar.ONNX":        SourceONNX,
+	} {
+		if got := localSource(model); got != want {
+			t.Errorf("localSource(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
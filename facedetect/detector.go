@@ -0,0 +1,55 @@
+// Package facedetect puts the face detection backends used by the capture
+// tools behind one interface, so a program can swap the Baidu API, the FDN
+// gateways, IBM Cloud Functions or the local SSD network without changes to
+// its capture loop.
+package facedetect
+
+import (
+	"context"
+	"encoding/base64"
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+// Backend names reported in Face.Source.
+const (
+	SourceBaidu    = "baidu"
+	SourceFDNBaidu = "fdn-baidu"
+	SourceZZ       = "zz"
+	SourceIBM      = "ibm"
+	SourceCaffe    = "caffe"
+
+	// Faces of a local network loaded from TensorFlow or ONNX weights; the
+	// local detector is still selected as SourceCaffe.
+	SourceTensorFlow = "tensorflow"
+	SourceONNX       = "onnx"
+)
+
+// Face is a single detection in pixel coordinates of the analysed frame.
+type Face struct {
+	Box        image.Rectangle // upright box as reported by the backend, see Corners and Bounds
+	Confidence float64         // 0..1, 1 when the backend does not report one
+	Rotation   float64         // clockwise rotation of Box around Box.Min in degrees
+	Source     string          // backend that produced the detection
+	Label      string          // class from the local model's label map
+	Attributes *Attributes     // nil when the backend reports none
+}
+
+// Detector finds faces in a frame. Implementations must not modify img.
+type Detector interface {
+	Detect(ctx context.Context, img gocv.Mat) ([]Face, error)
+}
+
+// encodeBase64 encodes img as a JPG image and returns it base64 encoded,
+// which is the form every remote backend expects.
+func encodeBase64(backend string, img gocv.Mat) (string, error) {
+	if img.Empty() {
+		return "", &Error{Backend: backend, Kind: ErrEncode, Msg: "empty frame"}
+	}
+	buf, err := gocv.IMEncode(".jpg", img)
+	if err != nil {
+		return "", &Error{Backend: backend, Kind: ErrEncode, Err: err}
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,71 @@
+package facedetect
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"gocv.io/x/gocv"
+)
+
+// DrawFaces draws a rectangle around each face on img, turned for tilted
+// faces.
+func DrawFaces(img *gocv.Mat, faces []Face, c color.RGBA, thickness int) {
+	for _, f := range faces {
+		if f.Rotation == 0 {
+			gocv.Rectangle(img, f.Box, c, thickness)
+			continue
+		}
+		corners := f.Corners()
+		for i, p := range corners {
+			gocv.Line(img, p, corners[(i+1)%len(corners)], c, thickness)
+		}
+	}
+}
+
+// DrawAttributes marks the landmarks of each face on img and writes its
+// attributes above the box.
+func DrawAttributes(img *gocv.Mat, faces []Face, c color.RGBA) {
+	for _, f := range faces {
+		a := f.Attributes
+		if a == nil {
+			continue
+		}
+		for _, l := range a.Landmarks {
+			gocv.Circle(img, l.Pt(), 1, c, -1)
+		}
+		if label := a.String(); label != "" {
+			b := f.Bounds()
+			org := image.Pt(b.Min.X, b.Min.Y-6)
+			gocv.PutText(img, label, org, gocv.FontHersheyPlain, 1.2, c, 1)
+		}
+	}
+}
+
+// String summarizes the attributes in a line, as drawn in the overlay.
+func (a *Attributes) String() string {
+	var parts []string
+	if a.Gender != "" {
+		parts = append(parts, a.Gender)
+	}
+	if a.Age > 0 {
+		parts = append(parts, fmt.Sprintf("age %.0f", a.Age))
+	}
+	if a.Expression != "" && a.Expression != "none" {
+		parts = append(parts, a.Expression)
+	}
+	if a.Glasses != "" && a.Glasses != "none" {
+		parts = append(parts, a.Glasses+" glasses")
+	}
+	if a.Beauty > 0 {
+		parts = append(parts, fmt.Sprintf("beauty %.0f", a.Beauty))
+	}
+	if q := a.Quality; q != nil {
+		parts = append(parts, fmt.Sprintf("blur %.2f", q.Blur))
+	}
+	if p := a.Angle; p != nil {
+		parts = append(parts, fmt.Sprintf("yaw %.0f pitch %.0f roll %.0f", p.Yaw, p.Pitch, p.Roll))
+	}
+	return strings.Join(parts, ", ")
+}
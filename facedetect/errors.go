@@ -0,0 +1,101 @@
+package facedetect
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of detector failures. Every error returned by a remote detector is
+// an *Error matching exactly one of them with errors.Is.
+var (
+	ErrEncode    = errors.New("cannot encode frame")
+	ErrTransport = errors.New("transport failure")       // no HTTP response
+	ErrStatus    = errors.New("unexpected HTTP status")  // response other than 2xx
+	ErrMalformed = errors.New("malformed response body") // response not in the expected shape
+	ErrAuth      = errors.New("authentication failed")
+	ErrQuota     = errors.New("quota exceeded")
+	ErrBackend   = errors.New("backend error") // any other error reported by the service
+)
+
+// Error is a failed detect call of a remote backend.
+type Error struct {
+	Backend    string // Source* name of the backend
+	Kind       error  // one of the Err* kinds above
+	StatusCode int    // HTTP status, 0 without a response
+	Code       int    // error_code reported by Baidu, 0 for none
+	Msg        string // message of the service or description of the failure
+	Err        error  // underlying cause, may be nil
+}
+
+func (e *Error) Error() string {
+	s := e.Backend + ": " + e.Kind.Error()
+	if e.StatusCode != 0 {
+		s += fmt.Sprintf(" (HTTP %d)", e.StatusCode)
+	}
+	if e.Code != 0 {
+		s += fmt.Sprintf(" error_code %d", e.Code)
+	}
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+// kinds lists the error kinds with their short names for ErrorKind.
+var kinds = []struct {
+	err  error
+	name string
+}{
+	{ErrEncode, "encode"},
+	{ErrTransport, "transport"},
+	{ErrStatus, "status"},
+	{ErrMalformed, "malformed"},
+	{ErrAuth, "auth"},
+	{ErrQuota, "quota"},
+	{ErrBackend, "backend"},
+}
+
+// ErrorKind returns a short name for the kind of err, suitable for counting
+// failures: "encode", "transport", "status", "malformed", "auth", "quota",
+// "backend", "dropped" for frames skipped by a rate limiter, "breaker" while
+// a circuit breaker is open, "canceled" for context errors, or "other".
+func ErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrDropped):
+		return "dropped"
+	case errors.Is(err, ErrCircuitOpen):
+		return "breaker"
+	}
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			return k.name
+		}
+	}
+	if isContextErr(err) {
+		return "canceled"
+	}
+	return "other"
+}
+
+// baiduCodeKind maps a Baidu error_code to an error kind. See
+// https://ai.baidu.com/ai-doc/FACE/5k37c1ujz for the list of codes.
+func baiduCodeKind(code int) error {
+	switch code {
+	case 6, 14, 100, baiduTokenInvalid, baiduTokenExpired:
+		// no permission, IAM failure, invalid or expired access token
+		return ErrAuth
+	case 4, 17, baiduQPSLimit, 19:
+		// request limits: cluster, daily, QPS, total
+		return ErrQuota
+	}
+	return ErrBackend
+}
@@ -0,0 +1,43 @@
+package facedetect
+
+import (
+	"context"
+	"net/http"
+
+	"gocv.io/x/gocv"
+)
+
+// fdnBaiduResponse is the FDN gateway envelope around a Baidu answer.
+type fdnBaiduResponse struct {
+	Body baiduResponse `json:"body"`
+}
+
+// FDNBaidu calls the Baidu face detect function deployed behind the FDN
+// gateway, which takes a form body.
+type FDNBaidu struct {
+	URL    string
+	Client *http.Client
+	Params BaiduParams
+}
+
+// NewFDNBaidu returns a detector posting to the FDN Baidu function at url.
+// The gateway uses a self-signed certificate; give the detector a Client
+// that trusts it, see package transport.
+func NewFDNBaidu(url string) *FDNBaidu {
+	return &FDNBaidu{URL: url}
+}
+
+// Detect implements Detector.
+func (f *FDNBaidu) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	imgBase64, err := encodeBase64(SourceFDNBaidu, img)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret fdnBaiduResponse
+	payload := f.Params.form(imgBase64)
+	if err := post(ctx, f.Client, SourceFDNBaidu, f.URL, contentForm, payload, &ret); err != nil {
+		return nil, err
+	}
+	return ret.Body.faces(SourceFDNBaidu)
+}
@@ -0,0 +1,81 @@
+package facedetect
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Corners returns the corners of the face box turned by Rotation, clockwise
+// from Box.Min. Baidu reports tilted faces as the size of the upright box
+// and the position of its top left corner after the turn, which is Box.Min.
+func (f *Face) Corners() [4]image.Point {
+	r := f.Box
+	if f.Rotation == 0 {
+		return [4]image.Point{r.Min, image.Pt(r.Max.X, r.Min.Y), r.Max, image.Pt(r.Min.X, r.Max.Y)}
+	}
+
+	sin, cos := math.Sincos(f.Rotation * math.Pi / 180)
+	w, h := float64(r.Dx()), float64(r.Dy())
+	x0, y0 := float64(r.Min.X), float64(r.Min.Y)
+	pt := func(dx, dy float64) image.Point {
+		// y grows downwards, so this turns (dx, dy) clockwise on screen
+		x := x0 + dx*cos - dy*sin
+		y := y0 + dx*sin + dy*cos
+		return image.Pt(int(math.Round(x)), int(math.Round(y)))
+	}
+	return [4]image.Point{pt(0, 0), pt(w, 0), pt(w, h), pt(0, h)}
+}
+
+// Bounds returns the axis-aligned bounding box of the turned face box.
+func (f *Face) Bounds() image.Rectangle {
+	if f.Rotation == 0 {
+		return f.Box
+	}
+	c := f.Corners()
+	b := image.Rectangle{Min: c[0], Max: c[0]}
+	for _, p := range c[1:] {
+		if p.X < b.Min.X {
+			b.Min.X = p.X
+		}
+		if p.Y < b.Min.Y {
+			b.Min.Y = p.Y
+		}
+		if p.X > b.Max.X {
+			b.Max.X = p.X
+		}
+		if p.Y > b.Max.Y {
+			b.Max.Y = p.Y
+		}
+	}
+	return b
+}
+
+// Chip returns the face cut out of img and turned upright, Box.Dx() by
+// Box.Dy() pixels. Parts of the box outside img are black. The caller must
+// close the returned Mat.
+func Chip(img gocv.Mat, f Face) gocv.Mat {
+	size := f.Box.Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return gocv.NewMat()
+	}
+	if f.Rotation == 0 {
+		if r := f.Box.Intersect(image.Rect(0, 0, img.Cols(), img.Rows())); r == f.Box {
+			region := img.Region(r)
+			defer region.Close()
+			return region.Clone()
+		}
+	}
+
+	// turn the frame back around the box's corner and shift that corner
+	// to the origin of the chip; OpenCV angles count counterclockwise
+	m := gocv.GetRotationMatrix2D(f.Box.Min, f.Rotation, 1)
+	defer m.Close()
+	m.SetDoubleAt(0, 2, m.GetDoubleAt(0, 2)-float64(f.Box.Min.X))
+	m.SetDoubleAt(1, 2, m.GetDoubleAt(1, 2)-float64(f.Box.Min.Y))
+
+	chip := gocv.NewMat()
+	gocv.WarpAffine(img, &chip, m, size)
+	return chip
+}
@@ -0,0 +1,52 @@
+package facedetect
+
+import (
+	"context"
+	"net/http"
+
+	"gocv.io/x/gocv"
+)
+
+// ibmResponse is the IBM Cloud Functions action envelope around a Baidu
+// answer.
+type ibmResponse struct {
+	DetecResult baiduResponse `json:"detec_result"`
+}
+
+// IBM calls the Baidu face detect action deployed on IBM Cloud Functions.
+// The action receives its parameters as a JSON object.
+type IBM struct {
+	URL      string
+	Username string // function key, sent with basic auth when set
+	Password string
+	Client   *http.Client
+	Params   BaiduParams
+}
+
+// NewIBM returns a detector invoking the IBM Cloud Functions action at url.
+func NewIBM(url string) *IBM {
+	return &IBM{URL: url}
+}
+
+// Detect implements Detector.
+func (b *IBM) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	imgBase64, err := encodeBase64(SourceIBM, img)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := b.Params.json(imgBase64)
+	if err != nil {
+		return nil, &Error{Backend: SourceIBM, Kind: ErrEncode, Err: err}
+	}
+	var result ibmResponse
+	auth := func(req *http.Request) {
+		if b.Username != "" {
+			req.SetBasicAuth(b.Username, b.Password)
+		}
+	}
+	if err := post(ctx, b.Client, SourceIBM, b.URL, contentJSON, payload, &result, auth); err != nil {
+		return nil, err
+	}
+	return result.DetecResult.faces(SourceIBM)
+}
@@ -0,0 +1,225 @@
+package facedetect
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// Local runs a face detection network with the OpenCV DNN module. The
+// bundled model is the res10 300x300 SSD Caffe net described by
+// LocalCaffeModel/deploy.prototxt; other networks, Caffe, TensorFlow or
+// ONNX, are described by a Manifest whose output layout picks the decoder.
+//
+// A Local is safe for concurrent use; forward passes are serialized on the
+// underlying gocv.Net.
+type Local struct {
+	mu       sync.Mutex
+	net      gocv.Net
+	manifest Manifest
+	source   string // Face.Source, after the format of the weights
+
+	// Post filters the detections and Tiling adds passes over parts of
+	// the frame; set them before the first Detect.
+	Post   PostProcess
+	Tiling Tiling
+}
+
+// NewLocal loads the network from model and config and prepares it for the
+// given backend and target. m describes the network, nil means
+// DefaultManifest; an empty config is taken from m.
+func NewLocal(model, config string, m *Manifest, backend gocv.NetBackendType, target gocv.NetTargetType) (*Local, error) {
+	if m == nil {
+		m = &DefaultManifest
+	}
+	if config == "" {
+		config = m.Config
+	}
+	if ModelFormat(model) == FormatONNX && config != "" {
+		// ReadNet would take the weights for Caffe because of a .prototxt
+		return nil, fmt.Errorf("ONNX model %v takes no network description, got %v", model, config)
+	}
+	net := gocv.ReadNet(model, config)
+	if net.Empty() {
+		return nil, fmt.Errorf("error reading network model from : %v %v", model, config)
+	}
+	net.SetPreferableBackend(backend)
+	net.SetPreferableTarget(target)
+	return &Local{net: net, manifest: *m, source: localSource(model)}, nil
+}
+
+// Source returns the Face.Source of the detections, after the format of
+// the weights the network was loaded from.
+func (l *Local) Source() string {
+	return l.source
+}
+
+// localSource returns the Face.Source of a network loaded from the weights
+// at model.
+func localSource(model string) string {
+	switch ModelFormat(model) {
+	case FormatTensorFlow:
+		return SourceTensorFlow
+	case FormatONNX:
+		return SourceONNX
+	}
+	return SourceCaffe
+}
+
+// Detect implements Detector.
+func (l *Local) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	passes, origins := l.passes(img)
+	defer closeTiles(passes)
+	found, err := l.forward(passes)
+	if err != nil {
+		return nil, err
+	}
+	return l.merge(found, origins, img.Cols(), img.Rows()), nil
+}
+
+// passes returns the images the network runs over for img, the frame itself
+// followed by its tiles, and where each of them starts in the frame. The
+// tiles are views into img; release them with closeTiles.
+func (l *Local) passes(img gocv.Mat) ([]gocv.Mat, []image.Point) {
+	passes := []gocv.Mat{img}
+	origins := []image.Point{{}}
+	for _, r := range l.Tiling.tiles(img.Cols(), img.Rows()) {
+		passes = append(passes, img.Region(r))
+		origins = append(origins, r.Min)
+	}
+	return passes, origins
+}
+
+// closeTiles releases the tiles returned by passes, but not the frame.
+func closeTiles(passes []gocv.Mat) {
+	for i := 1; i < len(passes); i++ {
+		passes[i].Close()
+	}
+}
+
+// merge maps the detections of each pass back to a frame of cols x rows
+// pixels and filters them with Post.
+func (l *Local) merge(found [][]Face, origins []image.Point, cols, rows int) []Face {
+	var faces []Face
+	for i, pass := range found {
+		for _, f := range pass {
+			faces = append(faces, f.translate(origins[i]))
+		}
+	}
+	post := l.Post.withDefaults()
+	if len(found) > 1 && post.NMS == NMSNone {
+		// the passes overlap, the same face must not be reported twice
+		post.NMS = NMSIoU
+	}
+	return post.Apply(faces, cols, rows)
+}
+
+// translate returns f moved by p, landmarks included.
+func (f Face) translate(p image.Point) Face {
+	f.Box = f.Box.Add(p)
+	if f.Attributes != nil && len(f.Attributes.Landmarks) > 0 && p != (image.Point{}) {
+		a := *f.Attributes
+		a.Landmarks = make([]Landmark, len(f.Attributes.Landmarks))
+		for i, lm := range f.Attributes.Landmarks {
+			lm.X += float64(p.X)
+			lm.Y += float64(p.Y)
+			a.Landmarks[i] = lm
+		}
+		f.Attributes = &a
+	}
+	return f
+}
+
+// forward runs the network once over all imgs and returns the detections
+// reaching the threshold of Post for each of them, in pixels of that image.
+func (l *Local) forward(imgs []gocv.Mat) ([][]Face, error) {
+	// convert the images to one blob of the input size that the object
+	// detector can analyze
+	in := l.manifest.Input
+	mean := gocv.NewScalar(in.Mean[0], in.Mean[1], in.Mean[2], 0)
+	blob := gocv.NewMat()
+	defer blob.Close()
+	gocv.BlobFromImages(imgs, &blob, in.Scale, image.Pt(in.Width, in.Height), mean, in.ChannelOrder == "RGB", false, gocv.MatTypeCV32F)
+
+	names := l.manifest.Output.layers()
+	l.mu.Lock()
+	// feed the blob into the detector
+	l.net.SetInput(blob, "")
+	// run a forward pass thru the network
+	var outs []gocv.Mat
+	switch len(names) {
+	case 0:
+		outs = []gocv.Mat{l.net.Forward("")}
+	case 1:
+		outs = []gocv.Mat{l.net.Forward(names[0])}
+	default:
+		outs = l.net.ForwardLayers(names)
+	}
+	l.mu.Unlock()
+	defer func() {
+		for i := range outs {
+			outs[i].Close()
+		}
+	}()
+	if len(names) > 1 && len(outs) != len(names) {
+		return nil, fmt.Errorf("%s: got %d outputs for %d layers", l.manifest.Name, len(outs), len(names))
+	}
+
+	sizes := make([]image.Point, len(imgs))
+	for i, img := range imgs {
+		sizes[i] = image.Pt(img.Cols(), img.Rows())
+	}
+	return decode(outs, sizes, &l.manifest, l.source, l.Post.withDefaults().Threshold)
+}
+
+// Close releases the network.
+func (l *Local) Close() error {
+	return l.net.Close()
+}
+
+// performDetection analyzes the results from the detector network,
+// which produces an output blob with a shape 1x1xNx7
+// where N is the number of detections, and each detection
+// is a vector of float values
+// [batchId, classId, confidence, left, top, right, bottom]
+// The detections are split by batchId, the index of the image in sizes
+// that gives its pixel size. Only the classes in labels are kept, unless it
+// is empty, and only detections reaching threshold. They are tagged with
+// source.
+func performDetection(results gocv.Mat, sizes []image.Point, labels map[int]string, source string, threshold float64) [][]Face {
+	faces := make([][]Face, len(sizes))
+
+	for i := 0; i < results.Total(); i += 7 {
+		batch := int(results.GetFloatAt(0, i))
+		if batch < 0 || batch >= len(sizes) {
+			// not a row of any image, such as the placeholder of an empty result
+			continue
+		}
+		label, ok := labels[int(results.GetFloatAt(0, i+1))]
+		if !ok && len(labels) > 0 {
+			continue
+		}
+		confidence := results.GetFloatAt(0, i+2)
+		if float64(confidence) >= threshold {
+			cols, rows := float32(sizes[batch].X), float32(sizes[batch].Y)
+			left := int(results.GetFloatAt(0, i+3) * cols)
+			top := int(results.GetFloatAt(0, i+4) * rows)
+			right := int(results.GetFloatAt(0, i+5) * cols)
+			bottom := int(results.GetFloatAt(0, i+6) * rows)
+			faces[batch] = append(faces[batch], Face{
+				Box:        image.Rect(left, top, right, bottom),
+				Confidence: float64(confidence),
+				Source:     source,
+				Label:      label,
+			})
+		}
+	}
+
+	return faces
+}
@@ -0,0 +1,202 @@
+package facedetect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output layouts a Manifest may name.
+const (
+	// LayoutSSD is the 1x1xNx7 detection_out blob of OpenCV's SSD nets,
+	// Caffe or TensorFlow, each row [batchId, classId, confidence, left,
+	// top, right, bottom] with coordinates relative to the frame size.
+	LayoutSSD = "ssd"
+	// LayoutUltraFace are the scores and boxes outputs of the ONNX export
+	// of Ultra-Light-Fast-Generic-Face-Detector, such as RFB-320.
+	LayoutUltraFace = "ultraface"
+	// LayoutYuNet are the twelve per stride outputs of the ONNX YuNet of
+	// OpenCV Zoo, which also finds five landmarks per face.
+	LayoutYuNet = "yunet"
+)
+
+// Model formats, told apart by the extension of the weights.
+const (
+	FormatCaffe      = "caffe"      // .caffemodel with a .prototxt description
+	FormatTensorFlow = "tensorflow" // frozen .pb graph, usually with a .pbtxt description
+	FormatONNX       = "onnx"       // .onnx, self-contained
+)
+
+// ModelFormat returns the format of the weights at path, "" if unknown.
+func ModelFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".caffemodel":
+		return FormatCaffe
+	case ".pb":
+		return FormatTensorFlow
+	case ".onnx":
+		return FormatONNX
+	}
+	return ""
+}
+
+// Manifest describes how a detection network wants its input and how its
+// output reads, so that networks other than the bundled one can be used
+// without code changes. It is kept next to the weights, see
+// ResolveManifest.
+type Manifest struct {
+	Name   string `yaml:"name" json:"name"`
+	Config string `yaml:"config" json:"config"` // network description, relative to the manifest
+
+	Input  InputSpec  `yaml:"input" json:"input"`
+	Output OutputSpec `yaml:"output" json:"output"`
+
+	// Labels names the classes that are reported. Detections of other
+	// classes, such as the background, are ignored. Empty keeps all.
+	Labels map[int]string `yaml:"labels" json:"labels"`
+}
+
+// InputSpec describes the input blob of a network.
+type InputSpec struct {
+	Width  int        `yaml:"width" json:"width"`
+	Height int        `yaml:"height" json:"height"`
+	Scale  float64    `yaml:"scale" json:"scale"` // multiplies the pixels after the mean is subtracted
+	Mean   [3]float64 `yaml:"mean" json:"mean"`   // subtracted per channel, in ChannelOrder
+
+	// ChannelOrder is BGR, the order of the frames, or RGB.
+	ChannelOrder string `yaml:"channel_order" json:"channel_order"`
+}
+
+// OutputSpec describes where and how a network reports its detections.
+type OutputSpec struct {
+	Layout string `yaml:"layout" json:"layout"` // LayoutSSD, LayoutUltraFace or LayoutYuNet
+	Name   string `yaml:"name" json:"name"`     // output layer of LayoutSSD, empty for the last one
+
+	// Names are the output layers of the other layouts in the order the
+	// layout documents, empty for the names of the reference export.
+	Names []string `yaml:"names" json:"names"`
+
+	// Priors marks LayoutUltraFace boxes as offsets from the priors, as in
+	// the exports without post-processing, instead of corners.
+	Priors bool `yaml:"priors" json:"priors"`
+}
+
+// layers returns the output layers to read, nil for the last one.
+func (o *OutputSpec) layers() []string {
+	if len(o.Names) > 0 {
+		return o.Names
+	}
+	if o.Name != "" {
+		return []string{o.Name}
+	}
+	return layouts[o.Layout].outputs
+}
+
+// DefaultManifest describes the bundled res10 300x300 SSD Caffe model; it
+// applies when the weights come without a manifest.
+var DefaultManifest = Manifest{
+	Name:   "res10_300x300_ssd",
+	Config: "LocalCaffeModel/deploy.prototxt",
+	Input: InputSpec{
+		Width:        300,
+		Height:       300,
+		Scale:        1,
+		Mean:         [3]float64{104, 177, 123},
+		ChannelOrder: "BGR",
+	},
+	Output: OutputSpec{Layout: LayoutSSD},
+	Labels: map[int]string{1: "face"},
+}
+
+// LoadManifest reads the YAML or JSON manifest at path. A relative Config
+// is taken relative to the manifest's directory.
+func LoadManifest(path string) (*Manifest, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(buf, m)
+	} else {
+		err = yaml.UnmarshalStrict(buf, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("manifest %v: %v", path, err)
+	}
+	if err := m.check(); err != nil {
+		return nil, fmt.Errorf("manifest %v: %v", path, err)
+	}
+	if m.Config != "" && !filepath.IsAbs(m.Config) {
+		m.Config = filepath.Join(filepath.Dir(path), m.Config)
+	}
+	return m, nil
+}
+
+// ResolveManifest returns the manifest at path if that is set. Otherwise it
+// looks next to the model weights for a file with the same base name and a
+// .yaml, .yml or .json extension, and falls back to DefaultManifest for
+// Caffe weights. Other formats need a manifest.
+func ResolveManifest(model, path string) (*Manifest, error) {
+	if path != "" {
+		return LoadManifest(path)
+	}
+	base := strings.TrimSuffix(model, filepath.Ext(model))
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		if _, err := os.Stat(base + ext); err == nil {
+			return LoadManifest(base + ext)
+		}
+	}
+	if ModelFormat(model) != FormatCaffe {
+		return nil, fmt.Errorf("no manifest next to %v: only Caffe weights default to the res10 SSD, describe other models with %v.yaml", model, base)
+	}
+	m := DefaultManifest
+	return &m, nil
+}
+
+// check fills in defaults and rejects values the detector cannot use.
+func (m *Manifest) check() error {
+	in := &m.Input
+	if in.Width == 0 && in.Height == 0 {
+		in.Width, in.Height = 300, 300
+	}
+	if in.Width <= 0 || in.Height <= 0 {
+		return fmt.Errorf("input size %dx%d is not positive", in.Width, in.Height)
+	}
+	if in.Scale == 0 {
+		in.Scale = 1
+	}
+	switch strings.ToUpper(in.ChannelOrder) {
+	case "", "BGR":
+		in.ChannelOrder = "BGR"
+	case "RGB":
+		in.ChannelOrder = "RGB"
+	default:
+		return fmt.Errorf("unknown channel_order %q, want BGR or RGB", in.ChannelOrder)
+	}
+
+	out := &m.Output
+	if out.Layout == "" {
+		out.Layout = LayoutSSD
+	}
+	l, ok := layouts[out.Layout]
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown output layout %q, want %s, %s or %s", out.Layout, LayoutSSD, LayoutUltraFace, LayoutYuNet)
+	case l.outputs == nil && len(out.Names) > 0:
+		return fmt.Errorf("output layout %s reads one layer, set name instead of names", out.Layout)
+	case l.outputs != nil && out.Name != "":
+		return fmt.Errorf("output layout %s reads %d layers, set names instead of name", out.Layout, len(l.outputs))
+	case len(out.Names) > 0 && len(out.Names) != len(l.outputs):
+		return fmt.Errorf("output layout %s reads %d layers, got %d names", out.Layout, len(l.outputs), len(out.Names))
+	case out.Priors && out.Layout != LayoutUltraFace:
+		return fmt.Errorf("priors only apply to output layout %s", LayoutUltraFace)
+	case out.Layout == LayoutYuNet && (in.Width%32 != 0 || in.Height%32 != 0):
+		return fmt.Errorf("output layout %s needs an input size in multiples of 32, got %dx%d", out.Layout, in.Width, in.Height)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package facedetect
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// Non-maximum suppression methods.
+const (
+	NMSIoU  = "iou"  // drop faces overlapping a stronger one by more than IoU
+	NMSSoft = "soft" // lower the confidence of overlapping faces instead (Gaussian soft-NMS)
+	NMSNone = "none"
+)
+
+// PostProcess filters the raw detections of a local network. Zero fields
+// take the value of DefaultPostProcess; a negative Threshold keeps every
+// detection.
+type PostProcess struct {
+	Threshold   float64 `yaml:"threshold"`     // minimum confidence, negative for none
+	NMS         string  `yaml:"nms"`           // NMSIoU, NMSSoft or NMSNone
+	IoU         float64 `yaml:"iou"`           // overlap that suppresses the weaker face with NMSIoU
+	SoftSigma   float64 `yaml:"soft_sigma"`    // decay of NMSSoft, smaller suppresses more
+	MaxFaces    int     `yaml:"max_faces"`     // strongest faces kept, 0 for all
+	MinFaceSize int     `yaml:"min_face_size"` // shorter side in pixels below which faces are dropped
+}
+
+// DefaultPostProcess matches what the bundled network was tuned for.
+var DefaultPostProcess = PostProcess{
+	Threshold: 0.5,
+	NMS:       NMSIoU,
+	IoU:       0.45,
+	SoftSigma: 0.5,
+}
+
+// withDefaults returns p with its zero fields taken from DefaultPostProcess.
+func (p PostProcess) withDefaults() PostProcess {
+	d := DefaultPostProcess
+	switch {
+	case p.Threshold == 0:
+		p.Threshold = d.Threshold
+	case p.Threshold < 0:
+		p.Threshold = 0
+	}
+	if p.NMS == "" {
+		p.NMS = d.NMS
+	}
+	if p.IoU == 0 {
+		p.IoU = d.IoU
+	}
+	if p.SoftSigma == 0 {
+		p.SoftSigma = d.SoftSigma
+	}
+	return p
+}
+
+// Check reports settings Apply cannot use.
+func (p PostProcess) Check() error {
+	switch p.NMS {
+	case "", NMSIoU, NMSSoft, NMSNone:
+	default:
+		return fmt.Errorf("unknown NMS method %q, want %s, %s or %s", p.NMS, NMSIoU, NMSSoft, NMSNone)
+	}
+	if p.Threshold > 1 || p.IoU < 0 || p.IoU > 1 {
+		return fmt.Errorf("threshold must be at most 1 and IoU between 0 and 1")
+	}
+	if p.SoftSigma < 0 || p.MaxFaces < 0 || p.MinFaceSize < 0 {
+		return fmt.Errorf("soft-NMS sigma, max faces and min face size must not be negative")
+	}
+	return nil
+}
+
+// Apply clamps the faces to a frame of cols x rows pixels, drops weak and
+// small ones, suppresses overlaps and keeps the strongest MaxFaces. The
+// result is ordered by descending confidence; faces is reused.
+func (p PostProcess) Apply(faces []Face, cols, rows int) []Face {
+	p = p.withDefaults()
+	frame := image.Rect(0, 0, cols, rows)
+
+	kept := faces[:0]
+	for _, f := range faces {
+		f.Box = f.Box.Canon().Intersect(frame)
+		size := f.Box.Size()
+		if f.Confidence < p.Threshold || f.Box.Empty() || size.X < p.MinFaceSize || size.Y < p.MinFaceSize {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	sortByConfidence(kept)
+
+	switch p.NMS {
+	case NMSIoU:
+		kept = nms(kept, p.IoU)
+	case NMSSoft:
+		kept = softNMS(kept, p.SoftSigma, p.Threshold)
+	}
+	if p.MaxFaces > 0 && len(kept) > p.MaxFaces {
+		kept = kept[:p.MaxFaces]
+	}
+	return kept
+}
+
+func sortByConfidence(faces []Face) {
+	sort.SliceStable(faces, func(i, j int) bool { return faces[i].Confidence > faces[j].Confidence })
+}
+
+// nms keeps each face that overlaps no stronger kept face by more than
+// threshold. faces must be sorted by descending confidence.
+func nms(faces []Face, threshold float64) []Face {
+	kept := faces[:0]
+	for _, f := range faces {
+		suppressed := false
+		for _, k := range kept {
+			if IoU(f.Box, k.Box) > threshold {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// softNMS repeatedly takes the strongest face and decays the confidence of
+// the others by exp(-iou²/sigma), dropping those that fall below threshold
+// (Bodla et al., 2017).
+func softNMS(faces []Face, sigma, threshold float64) []Face {
+	rest := append([]Face(nil), faces...)
+	kept := faces[:0]
+	for len(rest) > 0 {
+		best := 0
+		for i := range rest {
+			if rest[i].Confidence > rest[best].Confidence {
+				best = i
+			}
+		}
+		top := rest[best]
+		kept = append(kept, top)
+		rest = append(rest[:best], rest[best+1:]...)
+
+		remaining := rest[:0]
+		for _, f := range rest {
+			iou := IoU(top.Box, f.Box)
+			f.Confidence *= math.Exp(-iou * iou / sigma)
+			if f.Confidence >= threshold {
+				remaining = append(remaining, f)
+			}
+		}
+		rest = remaining
+	}
+	return kept
+}
+
+// IoU returns the intersection over union of two boxes.
+func IoU(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+	ia := area(inter)
+	return ia / (area(a) + area(b) - ia)
+}
+
+func area(r image.Rectangle) float64 {
+	return float64(r.Dx()) * float64(r.Dy())
+}
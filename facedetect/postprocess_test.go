@@ -0,0 +1,257 @@
+package facedetect
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// ssdBlob returns an SSD output blob of shape 1x1xNx7 holding rows.
+func ssdBlob(rows ...[7]float32) gocv.Mat {
+	m := gocv.NewMatWithSizes([]int{1, 1, len(rows), 7}, gocv.MatTypeCV32F)
+	for i, row := range rows {
+		for k, v := range row {
+			m.SetFloatAt(0, i*7+k, v)
+		}
+	}
+	return m
+}
+
+func TestPerformDetection(t *testing.T) {
+	sizes := []image.Point{{200, 100}, {400, 400}}
+	tests := []struct {
+		name   string
+		rows   [][7]float32
+		labels map[int]string
+		want   [][]Face
+	}{
+		{
+			name: "split by image",
+			rows: [][7]float32{
+				{0, 1, 0.75, 0.25, 0.5, 0.5, 1},
+				{1, 1, 0.5, 0, 0, 0.25, 0.25},
+			},
+			labels: map[int]string{1: "face"},
+			want: [][]Face{
+				{{Box: image.Rect(50, 50, 100, 100), Confidence: 0.75, Source: SourceCaffe, Label: "face"}},
+				{{Box: image.Rect(0, 0, 100, 100), Confidence: 0.5, Source: SourceCaffe, Label: "face"}},
+			},
+		},
+		{
+			name: "weak and unlabeled classes dropped",
+			rows: [][7]float32{
+				{0, 1, 0.25, 0, 0, 1, 1},
+				{0, 0, 0.75, 0, 0, 1, 1},
+				{0, 2, 0.75, 0, 0, 0.5, 0.5},
+			},
+			labels: map[int]string{1: "face", 2: "mask"},
+			want: [][]Face{
+				{{Box: image.Rect(0, 0, 100, 50), Confidence: 0.75, Source: SourceCaffe, Label: "mask"}},
+				nil,
+			},
+		},
+		{
+			name: "no labels keeps every class",
+			rows: [][7]float32{{1, 7, 1, 0, 0, 1, 1}},
+			want: [][]Face{nil, {{Box: image.Rect(0, 0, 400, 400), Confidence: 1, Source: SourceCaffe}}},
+		},
+		{
+			name: "placeholder and stray rows skipped",
+			rows: [][7]float32{
+				{-1, 0, 0, 0, 0, 0, 0},
+				{2, 1, 1, 0, 0, 1, 1},
+			},
+			labels: map[int]string{1: "face"},
+			want:   [][]Face{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := performDetection(ssdBlob(tt.rows...), sizes, tt.labels, SourceCaffe, 0.5)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIoU(t *testing.T) {
+	tests := []struct {
+		a, b image.Rectangle
+		want float64
+	}{
+		{image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), 1},
+		{image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 20), 0.5},
+		{image.Rect(0, 0, 10, 10), image.Rect(5, 0, 15, 10), 1.0 / 3},
+		{image.Rect(0, 0, 10, 10), image.Rect(10, 0, 20, 10), 0},
+	}
+	for _, tt := range tests {
+		if got := IoU(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("IoU(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// face returns a face with box r and confidence c.
+func face(c float64, r image.Rectangle) Face {
+	return Face{Box: r, Confidence: c}
+}
+
+func TestNMS(t *testing.T) {
+	a := image.Rect(0, 0, 10, 10)
+	half := image.Rect(0, 0, 10, 20)  // IoU 0.5 with a
+	third := image.Rect(5, 0, 15, 10) // IoU 1/3 with a
+	apart := image.Rect(50, 50, 60, 60)
+
+	tests := []struct {
+		name  string
+		faces []Face
+		iou   float64
+		want  []Face
+	}{
+		{"overlap above the limit", []Face{face(0.9, a), face(0.8, half)}, 0.45, []Face{face(0.9, a)}},
+		{"overlap below the limit", []Face{face(0.9, a), face(0.8, third)}, 0.45, []Face{face(0.9, a), face(0.8, third)}},
+		{"apart", []Face{face(0.9, a), face(0.8, apart)}, 0.1, []Face{face(0.9, a), face(0.8, apart)}},
+		// the third face only overlaps the suppressed second one
+		{"suppressed faces suppress nothing", []Face{face(0.9, a), face(0.8, third), face(0.7, image.Rect(10, 0, 20, 10))}, 0.3,
+			[]Face{face(0.9, a), face(0.7, image.Rect(10, 0, 20, 10))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nms(tt.faces, tt.iou); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoftNMS(t *testing.T) {
+	a := image.Rect(0, 0, 10, 10)
+	half := image.Rect(0, 0, 10, 20) // IoU 0.5 with a
+	apart := image.Rect(50, 50, 60, 60)
+
+	tests := []struct {
+		name  string
+		sigma float64
+		want  []Face
+	}{
+		// exp(-0.25/0.5) leaves 0.9 at 0.546, now weaker than the face apart
+		{"decayed", 0.5, []Face{face(1, a), face(0.6, apart), face(0.9*math.Exp(-0.5), half)}},
+		// exp(-0.25/0.25) takes it to 0.331, below the threshold
+		{"dropped", 0.25, []Face{face(1, a), face(0.6, apart)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			faces := []Face{face(1, a), face(0.9, half), face(0.6, apart)}
+			got := softNMS(faces, tt.sigma, 0.5)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Box != tt.want[i].Box || math.Abs(got[i].Confidence-tt.want[i].Confidence) > 1e-9 {
+					t.Fatalf("face %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		post  PostProcess
+		faces []Face
+		want  []Face
+	}{
+		{
+			name:  "clamped to the frame",
+			faces: []Face{face(0.9, image.Rect(-10, 90, 30, 120))},
+			want:  []Face{face(0.9, image.Rect(0, 90, 30, 100))},
+		},
+		{
+			name:  "reversed corners",
+			faces: []Face{face(0.9, image.Rect(30, 40, 10, 20))},
+			want:  []Face{face(0.9, image.Rect(10, 20, 30, 40))},
+		},
+		{
+			name:  "outside the frame",
+			faces: []Face{face(0.9, image.Rect(200, 0, 220, 20))},
+			want:  []Face{},
+		},
+		{
+			name:  "below the threshold",
+			post:  PostProcess{Threshold: 0.8},
+			faces: []Face{face(0.7, image.Rect(0, 0, 20, 20)), face(0.8, image.Rect(50, 50, 70, 70))},
+			want:  []Face{face(0.8, image.Rect(50, 50, 70, 70))},
+		},
+		{
+			name:  "no threshold",
+			post:  PostProcess{Threshold: -1},
+			faces: []Face{face(0.01, image.Rect(0, 0, 20, 20)), face(0, image.Rect(50, 50, 70, 70))},
+			want:  []Face{face(0.01, image.Rect(0, 0, 20, 20)), face(0, image.Rect(50, 50, 70, 70))},
+		},
+		{
+			// the box is cut to 8 pixels by the edge of the frame
+			name:  "min size after clamping",
+			post:  PostProcess{MinFaceSize: 10},
+			faces: []Face{face(0.9, image.Rect(0, 0, 10, 10)), face(0.8, image.Rect(92, 0, 102, 10))},
+			want:  []Face{face(0.9, image.Rect(0, 0, 10, 10))},
+		},
+		{
+			name: "strongest max faces",
+			post: PostProcess{MaxFaces: 2},
+			faces: []Face{
+				face(0.6, image.Rect(0, 0, 10, 10)),
+				face(0.9, image.Rect(20, 0, 30, 10)),
+				face(0.7, image.Rect(40, 0, 50, 10)),
+			},
+			want: []Face{face(0.9, image.Rect(20, 0, 30, 10)), face(0.7, image.Rect(40, 0, 50, 10))},
+		},
+		{
+			// max faces applies after suppression
+			name: "max faces after NMS",
+			post: PostProcess{MaxFaces: 2},
+			faces: []Face{
+				face(0.9, image.Rect(0, 0, 10, 10)),
+				face(0.8, image.Rect(0, 0, 10, 11)),
+				face(0.7, image.Rect(40, 0, 50, 10)),
+			},
+			want: []Face{face(0.9, image.Rect(0, 0, 10, 10)), face(0.7, image.Rect(40, 0, 50, 10))},
+		},
+		{
+			name:  "no NMS",
+			post:  PostProcess{NMS: NMSNone},
+			faces: []Face{face(0.8, image.Rect(0, 0, 10, 11)), face(0.9, image.Rect(0, 0, 10, 10))},
+			want:  []Face{face(0.9, image.Rect(0, 0, 10, 10)), face(0.8, image.Rect(0, 0, 10, 11))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.post.Apply(tt.faces, 100, 100); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostProcessCheck(t *testing.T) {
+	for _, p := range []PostProcess{
+		{NMS: "greedy"},
+		{Threshold: 1.5},
+		{IoU: -0.1},
+		{MaxFaces: -1},
+	} {
+		if p.Check() == nil {
+			t.Errorf("%+v passed the check", p)
+		}
+	}
+	if err := (PostProcess{}).Check(); err != nil {
+		t.Errorf("defaults: %v", err)
+	}
+	if err := (PostProcess{Threshold: -1}).Check(); err != nil {
+		t.Errorf("no threshold: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package facedetect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrDropped is returned by a RateLimited detector for a frame it skipped
+// rather than waiting for the rate limit.
+var ErrDropped = errors.New("frame dropped by rate limiter")
+
+// baiduQPSLimit is the error_code of "Open api qps request limit reached".
+const baiduQPSLimit = 18
+
+// RateLimitConfig configures a RateLimited detector.
+type RateLimitConfig struct {
+	QPS   float64 `yaml:"qps"`   // sustained requests per second, 0 for no limit
+	Burst int     `yaml:"burst"` // requests that may be sent back to back, at least 1
+
+	// With Drop set, a frame that would wait longer than MaxWait for its
+	// turn fails with ErrDropped instead; live capture then moves on to a
+	// fresher frame rather than building a backlog.
+	Drop    bool          `yaml:"drop"`
+	MaxWait time.Duration `yaml:"max_wait"`
+
+	// QuotaBackoff pauses all requests after the service reports its QPS
+	// limit (Baidu error_code 18 or HTTP 429), 0 means one second.
+	QuotaBackoff time.Duration `yaml:"quota_backoff"`
+}
+
+// BaiduFreeTier matches the QPS limit of Baidu's free face detect plan.
+var BaiduFreeTier = RateLimitConfig{QPS: 2, Burst: 1}
+
+// RateLimited paces calls of a remote detector with a token bucket and backs
+// off when the service reports that its QPS limit was hit. It is safe for
+// concurrent use if the wrapped detector is.
+type RateLimited struct {
+	d   Detector
+	cfg RateLimitConfig
+
+	mu     sync.Mutex
+	tokens float64   // may go negative: tokens reserved by waiting callers
+	last   time.Time // last refill
+	paused time.Time // no request before this time
+
+	dropped, throttled int64
+}
+
+// NewRateLimited wraps d.
+func NewRateLimited(d Detector, cfg RateLimitConfig) *RateLimited {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	if cfg.QuotaBackoff <= 0 {
+		cfg.QuotaBackoff = time.Second
+	}
+	return &RateLimited{d: d, cfg: cfg, tokens: float64(cfg.Burst), last: time.Now()}
+}
+
+// Detect implements Detector.
+func (r *RateLimited) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	if err := r.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return r.detectAcquired(ctx, img)
+}
+
+// acquire waits for the turn of a call. It fails with ErrDropped or the
+// error of ctx.
+func (r *RateLimited) acquire(ctx context.Context) error {
+	wait, ok := r.reserve()
+	if !ok {
+		atomic.AddInt64(&r.dropped, 1)
+		return ErrDropped
+	}
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			r.cancel()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// detectAcquired calls the wrapped detector for a call that acquired its
+// turn.
+func (r *RateLimited) detectAcquired(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	faces, err := r.d.Detect(ctx, img)
+	if quotaHit(err) {
+		atomic.AddInt64(&r.throttled, 1)
+		r.pause()
+	}
+	return faces, err
+}
+
+// reserve takes a token and returns how long the caller must wait for it.
+// It returns false when the frame should be dropped instead.
+func (r *RateLimited) reserve() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if now.Before(r.paused) {
+		wait = r.paused.Sub(now)
+	}
+	if r.cfg.QPS > 0 {
+		r.tokens += now.Sub(r.last).Seconds() * r.cfg.QPS
+		if max := float64(r.cfg.Burst); r.tokens > max {
+			r.tokens = max
+		}
+		r.last = now
+		if r.tokens < 1 {
+			if w := time.Duration((1 - r.tokens) / r.cfg.QPS * float64(time.Second)); w > wait {
+				wait = w
+			}
+		}
+	}
+
+	if r.cfg.Drop && wait > r.cfg.MaxWait {
+		return 0, false
+	}
+	if r.cfg.QPS > 0 {
+		r.tokens--
+	}
+	return wait, true
+}
+
+// cancel returns the token of a caller that gave up waiting.
+func (r *RateLimited) cancel() {
+	if r.cfg.QPS <= 0 {
+		return
+	}
+	r.mu.Lock()
+	r.tokens++
+	r.mu.Unlock()
+}
+
+// pause holds back all requests for QuotaBackoff and empties the bucket so
+// that they resume at the configured rate.
+func (r *RateLimited) pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if until := time.Now().Add(r.cfg.QuotaBackoff); until.After(r.paused) {
+		r.paused = until
+	}
+	if r.tokens > 0 {
+		r.tokens = 0
+	}
+}
+
+// quotaHit reports whether err says the service's QPS limit was reached.
+func quotaHit(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != ErrQuota {
+		return false
+	}
+	return e.Code == baiduQPSLimit || e.StatusCode == http.StatusTooManyRequests
+}
+
+// RateLimitStats counts what a RateLimited detector did.
+type RateLimitStats struct {
+	Dropped   int64 // frames skipped instead of waiting
+	Throttled int64 // QPS limit answers from the service
+}
+
+func (s RateLimitStats) String() string {
+	return fmt.Sprintf("dropped %d frames, QPS limit hit %d times", s.Dropped, s.Throttled)
+}
+
+// Stats returns a snapshot of the counters.
+func (r *RateLimited) Stats() RateLimitStats {
+	return RateLimitStats{
+		Dropped:   atomic.LoadInt64(&r.dropped),
+		Throttled: atomic.LoadInt64(&r.throttled),
+	}
+}
+
+// Close closes the wrapped detector if it holds resources.
+func (r *RateLimited) Close() error {
+	if c, ok := r.d.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
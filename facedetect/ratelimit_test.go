@@ -0,0 +1,134 @@
+package facedetect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// rewind moves the last refill of r back by d, as if d had passed.
+func rewind(r *RateLimited, d time.Duration) {
+	r.mu.Lock()
+	r.last = r.last.Add(-d)
+	r.mu.Unlock()
+}
+
+// passes counts the calls r lets through before it drops one.
+func passes(t *testing.T, r *RateLimited) int {
+	t.Helper()
+	for n := 0; ; n++ {
+		_, err := r.Detect(context.Background(), gocv.NewMat())
+		if errors.Is(err, ErrDropped) {
+			return n
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 100 {
+			t.Fatal("no call dropped")
+		}
+	}
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	r := NewRateLimited(&scriptedDetector{}, RateLimitConfig{QPS: 10, Burst: 3, Drop: true})
+	if n := passes(t, r); n != 3 {
+		t.Fatalf("%d calls back to back, want the burst of 3", n)
+	}
+	if s := r.Stats(); s.Dropped != 1 {
+		t.Fatalf("stats %v, want 1 dropped", s)
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	r := NewRateLimited(&scriptedDetector{}, RateLimitConfig{QPS: 10, Burst: 3, Drop: true})
+	passes(t, r)
+
+	// 10 QPS refills two tokens in 200ms
+	rewind(r, 200*time.Millisecond)
+	if n := passes(t, r); n != 2 {
+		t.Fatalf("%d calls after 200ms, want 2", n)
+	}
+	// a long pause refills no more than the burst
+	rewind(r, time.Minute)
+	if n := passes(t, r); n != 3 {
+		t.Fatalf("%d calls after a minute, want the burst of 3", n)
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	r := NewRateLimited(&scriptedDetector{}, RateLimitConfig{QPS: 10, Burst: 1})
+	if wait, ok := r.reserve(); !ok || wait != 0 {
+		t.Fatalf("first call waits %v, %v", wait, ok)
+	}
+	wait, ok := r.reserve()
+	if !ok || wait <= 90*time.Millisecond || wait > 100*time.Millisecond {
+		t.Fatalf("second call waits %v, %v, want about 100ms", wait, ok)
+	}
+
+	// a caller giving up returns its token, so the next one does not wait
+	// for it as well
+	r.cancel()
+	if wait, _ := r.reserve(); wait > 100*time.Millisecond {
+		t.Fatalf("call after a cancelled one waits %v, want at most 100ms", wait)
+	}
+}
+
+func TestRateLimitDropMaxWait(t *testing.T) {
+	r := NewRateLimited(&scriptedDetector{}, RateLimitConfig{QPS: 10, Burst: 1, Drop: true, MaxWait: 150 * time.Millisecond})
+	// calls arriving together: the second waits 100ms, within MaxWait, the
+	// third would wait 200ms
+	for i, want := range []bool{true, true, false} {
+		if _, ok := r.reserve(); ok != want {
+			t.Fatalf("call %d let through: %v, want %v", i, ok, want)
+		}
+	}
+}
+
+func TestRateLimitQuotaPause(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		pause bool
+	}{
+		{"QPS limit", &Error{Backend: SourceBaidu, Kind: ErrQuota, Code: baiduQPSLimit}, true},
+		{"HTTP 429", &Error{Backend: SourceZZ, Kind: ErrQuota, StatusCode: http.StatusTooManyRequests}, true},
+		{"daily limit", &Error{Backend: SourceBaidu, Kind: ErrQuota, Code: 17}, false},
+		{"other failure", errDown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &scriptedDetector{}
+			d.fail(tt.err)
+			r := NewRateLimited(d, RateLimitConfig{QPS: 100, Burst: 5, QuotaBackoff: time.Second})
+			if _, err := r.Detect(context.Background(), gocv.NewMat()); err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+
+			wait, _ := r.reserve()
+			if !tt.pause {
+				if wait != 0 || r.Stats().Throttled != 0 {
+					t.Fatalf("paused %v after %v", wait, tt.err)
+				}
+				return
+			}
+			if wait <= 900*time.Millisecond || wait > time.Second {
+				t.Fatalf("next call waits %v, want about the QuotaBackoff of 1s", wait)
+			}
+			if s := r.Stats(); s.Throttled != 1 {
+				t.Fatalf("stats %v, want the QPS limit hit once", s)
+			}
+			// the bucket was emptied, so calls resume at the configured rate
+			r.mu.Lock()
+			tokens := r.tokens
+			r.mu.Unlock()
+			if tokens >= 0 {
+				t.Fatalf("%v tokens left after the pause, want none", tokens)
+			}
+		})
+	}
+}
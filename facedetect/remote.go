@@ -0,0 +1,96 @@
+package facedetect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kkxu52452/videoCapAndProccess/transport"
+)
+
+// maxErrorBody bounds how much of an error response is kept in the message.
+const maxErrorBody = 512
+
+// Content types of the request bodies.
+const (
+	contentForm = "application/x-www-form-urlencoded"
+	contentJSON = "application/json"
+)
+
+// post sends payload of contentType to endpoint and decodes the JSON answer
+// into v. Each of opts may adjust the request before it is sent. Failures
+// are returned as *Error attributed to backend.
+func post(ctx context.Context, client *http.Client, backend, endpoint, contentType, payload string, v interface{}, opts ...func(*http.Request)) error {
+	if client == nil {
+		client = transport.Default()
+	}
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(payload))
+	if err != nil {
+		return &Error{Backend: backend, Kind: ErrTransport, Msg: "build request", Err: stripURL(err)}
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", contentJSON)
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, transport.ErrTooLarge) {
+			// resending the same frame would not help
+			return &Error{Backend: backend, Kind: ErrEncode, Err: stripURL(err)}
+		}
+		return &Error{Backend: backend, Kind: ErrTransport, Err: stripURL(err)}
+	}
+	defer res.Body.Close()
+
+	if err := statusError(backend, res); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return &Error{Backend: backend, Kind: ErrMalformed, StatusCode: res.StatusCode, Err: err}
+	}
+	return nil
+}
+
+// statusError returns nil for a 2xx response and an *Error carrying the
+// start of the body otherwise.
+func statusError(backend string, res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	kind := ErrStatus
+	switch res.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = ErrAuth
+	case http.StatusTooManyRequests:
+		kind = ErrQuota
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	return &Error{Backend: backend, Kind: kind, StatusCode: res.StatusCode, Msg: strings.TrimSpace(string(body))}
+}
+
+// stripURL leaves the URL out of a *url.Error, it may carry an access token.
+func stripURL(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err
+	}
+	return err
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
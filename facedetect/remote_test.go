@@ -0,0 +1,300 @@
+package facedetect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+// testParams are sent by every backend that forwards Baidu parameters.
+var testParams = BaiduParams{FaceField: "age,landmark", MaxFaceNum: 5, FaceType: "LIVE", LivenessControl: "LOW"}
+
+// baiduFields are testParams and the test image as they reach Baidu. The
+// base64 of the test image holds + and /, which a form must escape.
+func baiduFields(t *testing.T) map[string]string {
+	img := testImage()
+	defer img.Close()
+	imgBase64, err := encodeBase64("test", img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"image":            imgBase64,
+		"image_type":       "BASE64",
+		"face_field":       "age,landmark",
+		"max_face_num":     "5",
+		"face_type":        "LIVE",
+		"liveness_control": "LOW",
+	}
+}
+
+func testImage() gocv.Mat {
+	return gocv.NewMatWithSize(2, 2, gocv.MatTypeCV8UC3)
+}
+
+// requestFields returns the fields of a JSON or form body, with JSON
+// numbers in their decimal form.
+func requestFields(r *http.Request) (map[string]string, error) {
+	ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	switch ct {
+	case contentJSON:
+		var body map[string]interface{}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil {
+			return nil, err
+		}
+		for k, v := range body {
+			if _, ok := v.(string); !ok && k != "max_face_num" {
+				return nil, fmt.Errorf("field %s is not a string: %v", k, v)
+			}
+			fields[k] = fmt.Sprint(v)
+		}
+	case contentForm:
+		buf, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		form, err := url.ParseQuery(string(buf))
+		if err != nil {
+			return nil, err
+		}
+		for k := range form {
+			fields[k] = form.Get(k)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected content type %s", ct)
+	}
+	return fields, nil
+}
+
+// contract describes what one backend expects and answers.
+type contract struct {
+	name        string
+	path        string
+	query       url.Values
+	contentType string
+	user, pass  string // basic auth, empty for none
+	fields      map[string]string
+	detector    func(url string) Detector
+}
+
+func contracts(t *testing.T) []contract {
+	all := baiduFields(t)
+	return []contract{
+		{
+			name:        SourceBaidu,
+			path:        "/rest/2.0/face/v3/detect",
+			query:       url.Values{"access_token": {"24.token"}},
+			contentType: contentJSON,
+			fields:      all,
+			detector: func(u string) Detector {
+				b := NewBaidu(u + "/rest/2.0/face/v3/detect?access_token=24.token")
+				b.Params = testParams
+				return b
+			},
+		},
+		{
+			name:        SourceFDNBaidu,
+			path:        "/api/tenant/face-detect-Baidu/facedetec/face-detect-Baidu",
+			contentType: contentForm,
+			fields:      all,
+			detector: func(u string) Detector {
+				f := NewFDNBaidu(u + "/api/tenant/face-detect-Baidu/facedetec/face-detect-Baidu")
+				f.Params = testParams
+				return f
+			},
+		},
+		{
+			name:        SourceZZ,
+			path:        "/api/tenant/facedetec/face-detect-FDN",
+			contentType: contentForm,
+			// zz takes no Baidu parameters
+			fields: map[string]string{"image": all["image"], "image_type": "BASE64"},
+			detector: func(u string) Detector {
+				return NewZZ(u + "/api/tenant/facedetec/face-detect-FDN")
+			},
+		},
+		{
+			name:        SourceIBM,
+			path:        "/api/v1/namespaces/ns/actions/test/IBMbaiduAPI",
+			contentType: contentJSON,
+			user:        "key-uuid",
+			pass:        "secret",
+			fields:      all,
+			detector: func(u string) Detector {
+				b := NewIBM(u + "/api/v1/namespaces/ns/actions/test/IBMbaiduAPI")
+				b.Username, b.Password = "key-uuid", "secret"
+				b.Params = testParams
+				return b
+			},
+		},
+	}
+}
+
+// check reports how r breaks the contract, nil if it does not.
+func (c *contract) check(r *http.Request) error {
+	if r.Method != "POST" || r.URL.Path != c.path {
+		return fmt.Errorf("got %s %s, want POST %s", r.Method, r.URL.Path, c.path)
+	}
+	if c.query != nil && !reflect.DeepEqual(r.URL.Query(), c.query) {
+		return fmt.Errorf("got query %v, want %v", r.URL.Query(), c.query)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != c.contentType {
+		return fmt.Errorf("got Content-Type %q, want %q", ct, c.contentType)
+	}
+	if accept := r.Header.Get("Accept"); accept != contentJSON {
+		return fmt.Errorf("got Accept %q, want %q", accept, contentJSON)
+	}
+	user, pass, ok := r.BasicAuth()
+	if ok != (c.user != "") || user != c.user || pass != c.pass {
+		return fmt.Errorf("got basic auth %q:%q, want %q:%q", user, pass, c.user, c.pass)
+	}
+	fields, err := requestFields(r)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(fields, c.fields) {
+		return fmt.Errorf("got fields %v, want %v", fields, c.fields)
+	}
+	return nil
+}
+
+// serve starts a server that checks each request against c and answers
+// with status and body.
+func (c *contract) serve(t *testing.T, status int, body string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := c.check(r); err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		w.Header().Set("Content-Type", contentJSON)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// detect runs the detector of c against srv.
+func (c *contract) detect(srv *httptest.Server) ([]Face, error) {
+	img := testImage()
+	defer img.Close()
+	return c.detector(srv.URL).Detect(context.Background(), img)
+}
+
+// baiduAnswer is a Baidu detect answer with one face.
+const baiduAnswer = `{"error_code":0,"error_msg":"SUCCESS","result":{"face_num":1,"face_list":[` +
+	`{"face_token":"f1","location":{"left":10.5,"top":20,"width":30,"height":40,"rotation":-5},"face_probability":0.75}]}}`
+
+// answer wraps a Baidu answer in the envelope of each backend; zz answers
+// in its own format.
+func answer(backend, baidu string) string {
+	switch backend {
+	case SourceFDNBaidu:
+		return `{"body":` + baidu + `}`
+	case SourceIBM:
+		return `{"detec_result":` + baidu + `}`
+	}
+	return baidu
+}
+
+func TestRemoteContract(t *testing.T) {
+	for _, c := range contracts(t) {
+		t.Run(c.name, func(t *testing.T) {
+			body := answer(c.name, baiduAnswer)
+			want := Face{Box: image.Rect(10, 20, 40, 60), Confidence: 0.75, Rotation: -5, Source: c.name}
+			if c.name == SourceZZ {
+				body = `{"face_ret":{"faces":[{"left":10.5,"top":20,"width":30,"height":40}]}}`
+				want.Confidence, want.Rotation = 1, 0
+			}
+			faces, err := c.detect(c.serve(t, http.StatusOK, body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(faces) != 1 {
+				t.Fatalf("got %d faces, want 1", len(faces))
+			}
+			got := faces[0]
+			got.Attributes = nil
+			if got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestRemoteNoFace(t *testing.T) {
+	// Baidu's error_code 222202 is an empty result
+	for _, c := range contracts(t) {
+		if c.name == SourceZZ {
+			continue
+		}
+		t.Run(c.name, func(t *testing.T) {
+			body := answer(c.name, `{"error_code":222202,"error_msg":"pic not has face","result":null}`)
+			faces, err := c.detect(c.serve(t, http.StatusOK, body))
+			if err != nil || len(faces) != 0 {
+				t.Fatalf("got %v, %v, want no faces", faces, err)
+			}
+		})
+	}
+}
+
+func TestRemoteErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string // Baidu answers are put into the backend's envelope
+		baidu  bool
+		kind   error
+		code   int
+	}{
+		{"QPS limit", http.StatusOK, `{"error_code":18,"error_msg":"Open api qps request limit reached"}`, true, ErrQuota, 18},
+		{"daily limit", http.StatusOK, `{"error_code":17,"error_msg":"Open api daily request limit reached"}`, true, ErrQuota, 17},
+		{"no permission", http.StatusOK, `{"error_code":6,"error_msg":"No permission to access data"}`, true, ErrAuth, 6},
+		{"bad image", http.StatusOK, `{"error_code":222203,"error_msg":"image check fail"}`, true, ErrBackend, 222203},
+		{"unauthorized", http.StatusUnauthorized, `{"error":"The supplied authentication is invalid"}`, false, ErrAuth, 0},
+		{"too many requests", http.StatusTooManyRequests, `{}`, false, ErrQuota, 0},
+		{"server error", http.StatusBadGateway, `upstream unavailable`, false, ErrStatus, 0},
+		{"malformed", http.StatusOK, `<html>`, false, ErrMalformed, 0},
+	}
+	for _, c := range contracts(t) {
+		for _, tt := range tests {
+			if tt.baidu && c.name == SourceZZ {
+				continue
+			}
+			t.Run(c.name+"/"+tt.name, func(t *testing.T) {
+				body := tt.body
+				if tt.baidu {
+					body = answer(c.name, body)
+				}
+				_, err := c.detect(c.serve(t, tt.status, body))
+				var e *Error
+				if !errors.As(err, &e) || !errors.Is(err, tt.kind) || e.Code != tt.code || e.Backend != c.name {
+					t.Fatalf("got %v, want a %s error of %s with code %d", err, tt.kind, c.name, tt.code)
+				}
+				if tt.status != http.StatusOK && e.StatusCode != tt.status {
+					t.Fatalf("got status %d, want %d", e.StatusCode, tt.status)
+				}
+				if tt.status == http.StatusBadGateway && !strings.Contains(e.Msg, "upstream unavailable") {
+					t.Fatalf("error %q leaves out the body", e.Msg)
+				}
+			})
+		}
+	}
+}
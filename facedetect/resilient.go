@@ -0,0 +1,287 @@
+package facedetect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// ErrCircuitOpen is returned without calling the backend while the circuit
+// breaker of a Resilient detector is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ResilienceConfig tunes a Resilient detector.
+type ResilienceConfig struct {
+	Timeout     time.Duration // limit of each attempt, 0 for none
+	MaxRetries  int           // attempts after the first one
+	BaseBackoff time.Duration // backoff before the first retry, doubled for each further one
+	MaxBackoff  time.Duration // cap of the backoff
+
+	// The breaker opens after BreakerThreshold consecutive retryable
+	// failures, 0 disables it. While open, calls fail immediately; after
+	// BreakerCooldown one probe call at a time is let through: a success
+	// closes the breaker, a retryable failure reopens it.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultResilience suits the remote face APIs.
+var DefaultResilience = ResilienceConfig{
+	Timeout:          10 * time.Second,
+	MaxRetries:       2,
+	BaseBackoff:      200 * time.Millisecond,
+	MaxBackoff:       5 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// Circuit breaker states.
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// ResilienceStats counts what a Resilient detector did.
+type ResilienceStats struct {
+	Calls    int64 // Detect calls
+	Attempts int64 // calls of the wrapped detector
+	Retries  int64
+	Failures int64 // calls that returned an error
+	Opens    int64 // times the breaker opened
+	Rejected int64 // calls failed with ErrCircuitOpen
+	State    string
+}
+
+func (s ResilienceStats) String() string {
+	return fmt.Sprintf("calls %d, attempts %d, retries %d, failures %d, breaker opened %d times, rejected %d, now %s",
+		s.Calls, s.Attempts, s.Retries, s.Failures, s.Opens, s.Rejected, s.State)
+}
+
+// Resilient wraps a remote detector with per-attempt timeouts, retries with
+// jittered exponential backoff, and a circuit breaker. It is safe for
+// concurrent use if the wrapped detector is.
+//
+// A RateLimited detector is best wrapped by Resilient, so that retries are
+// paced too. Resilient waits for the rate limiter before it starts the
+// attempt timeout, and neither the wait nor a dropped frame counts against
+// the backend.
+type Resilient struct {
+	d   Detector
+	cfg ResilienceConfig
+
+	mu       sync.Mutex
+	state    int
+	failures int // consecutive retryable failures
+	openedAt time.Time
+	probing  bool
+
+	calls, attempts, retries, failed, opens, rejected int64
+}
+
+// pacer is implemented by detectors that hold calls back before they reach
+// the backend, like RateLimited.
+type pacer interface {
+	// acquire waits for the turn of a call
+	acquire(ctx context.Context) error
+	// detectAcquired makes a call that acquired its turn
+	detectAcquired(ctx context.Context, img gocv.Mat) ([]Face, error)
+}
+
+// NewResilient wraps d.
+func NewResilient(d Detector, cfg ResilienceConfig) *Resilient {
+	return &Resilient{d: d, cfg: cfg}
+}
+
+// Detect implements Detector.
+func (r *Resilient) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	atomic.AddInt64(&r.calls, 1)
+	faces, err := r.detect(ctx, img)
+	if err != nil {
+		atomic.AddInt64(&r.failed, 1)
+	}
+	return faces, err
+}
+
+func (r *Resilient) detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if !r.allow() {
+			atomic.AddInt64(&r.rejected, 1)
+			return nil, ErrCircuitOpen
+		}
+
+		detect := r.d.Detect
+		if p, ok := r.d.(pacer); ok {
+			if err := p.acquire(ctx); err != nil {
+				// a frame dropped or cancelled before it reached the backend;
+				// a retry that never ran reports the failure it retried
+				r.release()
+				if attempt > 0 {
+					return nil, lastErr
+				}
+				return nil, err
+			}
+			detect = p.detectAcquired
+		}
+
+		atomic.AddInt64(&r.attempts, 1)
+		faces, err := r.attempt(ctx, detect, img)
+		if ctx.Err() != nil {
+			// cancelled by the caller, says nothing about the backend
+			r.release()
+			return nil, ctx.Err()
+		}
+		retryable := Retryable(err)
+		r.record(err == nil, retryable)
+		if err == nil || !retryable || attempt >= r.cfg.MaxRetries {
+			return faces, err
+		}
+		lastErr = err
+
+		atomic.AddInt64(&r.retries, 1)
+		select {
+		case <-time.After(r.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt calls detect once under the per-attempt timeout.
+func (r *Resilient) attempt(ctx context.Context, detect func(context.Context, gocv.Mat) ([]Face, error), img gocv.Mat) ([]Face, error) {
+	if r.cfg.Timeout <= 0 {
+		return detect(ctx, img)
+	}
+	actx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	return detect(actx, img)
+}
+
+// backoff returns the wait before retry number attempt+1, drawn uniformly
+// between zero and the exponential bound ("full jitter").
+func (r *Resilient) backoff(attempt int) time.Duration {
+	d := r.cfg.BaseBackoff << uint(attempt)
+	if d <= 0 || (r.cfg.MaxBackoff > 0 && d > r.cfg.MaxBackoff) {
+		d = r.cfg.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// allow reports whether a call may go to the backend now.
+func (r *Resilient) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.state {
+	case breakerOpen:
+		if time.Since(r.openedAt) < r.cfg.BreakerCooldown {
+			return false
+		}
+		r.state = breakerHalfOpen
+		r.probing = true
+		return true
+	case breakerHalfOpen:
+		if r.probing {
+			return false
+		}
+		r.probing = true
+		return true
+	}
+	return true
+}
+
+// release gives up a probe slot without a verdict.
+func (r *Resilient) release() {
+	r.mu.Lock()
+	r.probing = false
+	r.mu.Unlock()
+}
+
+// record updates the breaker with the outcome of an attempt. Only retryable
+// failures count against the backend; a rejected frame is not an outage, but
+// neither does it show that the backend recovered, so it leaves the breaker
+// as it is and a half-open breaker waits for the next probe.
+func (r *Resilient) record(ok, retryable bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probing = false
+
+	if ok {
+		r.state = breakerClosed
+		r.failures = 0
+		return
+	}
+	if !retryable {
+		return
+	}
+	r.failures++
+	if r.cfg.BreakerThreshold <= 0 {
+		return
+	}
+	if r.state == breakerHalfOpen || r.failures >= r.cfg.BreakerThreshold {
+		if r.state != breakerOpen {
+			atomic.AddInt64(&r.opens, 1)
+		}
+		r.state = breakerOpen
+		r.openedAt = time.Now()
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (r *Resilient) Stats() ResilienceStats {
+	r.mu.Lock()
+	state := [...]string{"closed", "open", "half-open"}[r.state]
+	r.mu.Unlock()
+	return ResilienceStats{
+		Calls:    atomic.LoadInt64(&r.calls),
+		Attempts: atomic.LoadInt64(&r.attempts),
+		Retries:  atomic.LoadInt64(&r.retries),
+		Failures: atomic.LoadInt64(&r.failed),
+		Opens:    atomic.LoadInt64(&r.opens),
+		Rejected: atomic.LoadInt64(&r.rejected),
+		State:    state,
+	}
+}
+
+// Close closes the wrapped detector if it holds resources.
+func (r *Resilient) Close() error {
+	if c, ok := r.d.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Retryable reports whether a failed detect call may succeed when repeated:
+// transport failures, attempt timeouts, server side HTTP errors and rate
+// limiting.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTransport) {
+		return true
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch {
+	case e.Kind == ErrStatus:
+		return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout
+	case e.Kind == ErrQuota:
+		// QPS limits clear up quickly, daily and total quotas do not
+		return e.Code == baiduQPSLimit || e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package facedetect
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+var (
+	errDown     = &Error{Backend: "test", Kind: ErrTransport, Msg: "connection refused"}
+	errRejected = &Error{Backend: "test", Kind: ErrMalformed, Msg: "not an image"}
+)
+
+// scriptedDetector returns the errors of script in turn, then succeeds. A
+// call blocks while gate is set and open.
+type scriptedDetector struct {
+	mu     sync.Mutex
+	script []error
+	calls  int
+	gate   chan struct{}
+}
+
+func (d *scriptedDetector) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	d.mu.Lock()
+	d.calls++
+	var err error
+	if len(d.script) > 0 {
+		err, d.script = d.script[0], d.script[1:]
+	}
+	gate := d.gate
+	d.mu.Unlock()
+	if gate != nil {
+		select {
+		case <-gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return nil, err
+}
+
+func (d *scriptedDetector) fail(errs ...error) {
+	d.mu.Lock()
+	d.script = append(d.script, errs...)
+	d.mu.Unlock()
+}
+
+// newBreaker returns a Resilient without retries whose breaker opens after
+// two failures.
+func newBreaker(d Detector) *Resilient {
+	return NewResilient(d, ResilienceConfig{BreakerThreshold: 2, BreakerCooldown: time.Hour})
+}
+
+// cooledDown lets the cooldown of an open breaker pass.
+func cooledDown(r *Resilient) {
+	r.mu.Lock()
+	r.openedAt = time.Now().Add(-2 * r.cfg.BreakerCooldown)
+	r.mu.Unlock()
+}
+
+// expect calls r and checks the error and the state of the breaker after it.
+func expect(t *testing.T, r *Resilient, wantErr error, wantState string) {
+	t.Helper()
+	_, err := r.Detect(context.Background(), gocv.NewMat())
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got %v, want %v", err, wantErr)
+	}
+	if state := r.Stats().State; state != wantState {
+		t.Fatalf("breaker %s, want %s", state, wantState)
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	d := &scriptedDetector{script: []error{errDown, errDown}}
+	r := newBreaker(d)
+	expect(t, r, errDown, "closed")
+	expect(t, r, errDown, "open")
+	expect(t, r, ErrCircuitOpen, "open")
+	if d.calls != 2 {
+		t.Fatalf("backend called %d times, want 2, not while open", d.calls)
+	}
+	if s := r.Stats(); s.Opens != 1 || s.Rejected != 1 {
+		t.Fatalf("stats %v, want 1 open and 1 rejected", s)
+	}
+}
+
+func TestBreakerIgnoresRejectedFrames(t *testing.T) {
+	// a frame the backend rejects neither counts as a failure nor resets
+	// the count of consecutive failures
+	d := &scriptedDetector{script: []error{errDown, errRejected, errRejected, errDown}}
+	r := newBreaker(d)
+	expect(t, r, errDown, "closed")
+	expect(t, r, errRejected, "closed")
+	expect(t, r, errRejected, "closed")
+	expect(t, r, errDown, "open")
+}
+
+func TestBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		probes []error // outcomes of the probes after the cooldown
+		states []string
+	}{
+		{"success closes", []error{nil}, []string{"closed"}},
+		{"failure reopens", []error{errDown}, []string{"open"}},
+		{"rejected frame keeps it half-open", []error{errRejected, errRejected, nil},
+			[]string{"half-open", "half-open", "closed"}},
+		{"rejected frame then failure reopens", []error{errRejected, errDown}, []string{"half-open", "open"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &scriptedDetector{script: []error{errDown, errDown}}
+			r := newBreaker(d)
+			expect(t, r, errDown, "closed")
+			expect(t, r, errDown, "open")
+			cooledDown(r)
+
+			for i, err := range tt.probes {
+				if err != nil {
+					d.fail(err)
+				}
+				expect(t, r, err, tt.states[i])
+			}
+			wantOpens := int64(1)
+			if tt.states[len(tt.states)-1] == "open" {
+				wantOpens = 2
+			}
+			if opens := r.Stats().Opens; opens != wantOpens {
+				t.Fatalf("breaker opened %d times, want %d", opens, wantOpens)
+			}
+		})
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	d := &scriptedDetector{script: []error{errDown, errDown}}
+	r := newBreaker(d)
+	expect(t, r, errDown, "closed")
+	expect(t, r, errDown, "open")
+	cooledDown(r)
+
+	// while the probe is running, other calls are turned away
+	d.gate = make(chan struct{})
+	probed := make(chan error)
+	go func() {
+		_, err := r.Detect(context.Background(), gocv.NewMat())
+		probed <- err
+	}()
+	for r.Stats().State != "half-open" {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := r.Detect(context.Background(), gocv.NewMat()); err != ErrCircuitOpen {
+		t.Fatalf("second call during the probe: got %v, want ErrCircuitOpen", err)
+	}
+	close(d.gate)
+	if err := <-probed; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if state := r.Stats().State; state != "closed" {
+		t.Fatalf("breaker %s after a successful probe, want closed", state)
+	}
+}
+
+func TestBreakerCancelledProbe(t *testing.T) {
+	d := &scriptedDetector{script: []error{errDown, errDown}}
+	r := newBreaker(d)
+	expect(t, r, errDown, "closed")
+	expect(t, r, errDown, "open")
+	cooledDown(r)
+
+	// a probe cancelled by its caller gives its slot to the next call
+	d.gate = make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Detect(ctx, gocv.NewMat()); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if state := r.Stats().State; state != "half-open" {
+		t.Fatalf("breaker %s after a cancelled probe, want half-open", state)
+	}
+	d.gate = nil
+	expect(t, r, nil, "closed")
+}
+
+func TestResilientRateLimitWait(t *testing.T) {
+	// waiting for the rate limiter does not eat into the attempt timeout
+	d := &scriptedDetector{}
+	r := NewResilient(NewRateLimited(d, RateLimitConfig{QPS: 10, Burst: 1}),
+		ResilienceConfig{Timeout: 20 * time.Millisecond, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	for i := 0; i < 3; i++ {
+		expect(t, r, nil, "closed")
+	}
+	if s := r.Stats(); s.Attempts != 3 || s.Failures != 0 {
+		t.Fatalf("stats %v, want 3 attempts and no failures", s)
+	}
+}
+
+func TestResilientRateLimitDrop(t *testing.T) {
+	// a dropped frame never reached the backend
+	d := &scriptedDetector{}
+	r := NewResilient(NewRateLimited(d, RateLimitConfig{QPS: 1, Burst: 1, Drop: true}),
+		ResilienceConfig{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	expect(t, r, nil, "closed")
+	expect(t, r, ErrDropped, "closed")
+	if s := r.Stats(); s.Attempts != 1 {
+		t.Fatalf("stats %v, want 1 attempt", s)
+	}
+	if d.calls != 1 {
+		t.Fatalf("backend called %d times, want 1", d.calls)
+	}
+}
+
+func TestResilientRetryDropped(t *testing.T) {
+	// the retry of a failed call is dropped by the limiter: the caller
+	// learns why the call failed, not that a frame was skipped
+	d := &scriptedDetector{}
+	d.fail(errDown)
+	r := NewResilient(NewRateLimited(d, RateLimitConfig{QPS: 1, Burst: 1, Drop: true}),
+		ResilienceConfig{MaxRetries: 2, BreakerThreshold: 5, BreakerCooldown: time.Hour})
+	expect(t, r, errDown, "closed")
+	if d.calls != 1 {
+		t.Fatalf("backend called %d times, want 1", d.calls)
+	}
+}
@@ -0,0 +1,63 @@
+package facedetect
+
+import (
+	"fmt"
+	"image"
+)
+
+// Tiling makes a local detector run its network over overlapping square
+// tiles of the frame in addition to the pass over the whole frame. Every
+// pass is scaled down to the network's input size, so a tile keeps faces
+// large enough to be found that vanish when a 1080p frame shrinks to
+// 300x300. The detections of all passes are merged with non-maximum
+// suppression.
+type Tiling struct {
+	Size    int     `yaml:"size"`    // tile side in pixels, 0 disables tiling
+	Overlap float64 `yaml:"overlap"` // share of a tile's side covered by its neighbor, 0..0.9
+}
+
+// Check reports settings the detector cannot use.
+func (t Tiling) Check() error {
+	if t.Size < 0 {
+		return fmt.Errorf("tile size must not be negative")
+	}
+	if t.Overlap < 0 || t.Overlap > 0.9 {
+		return fmt.Errorf("tile overlap must be between 0 and 0.9")
+	}
+	return nil
+}
+
+// tiles returns the tiles of a cols x rows frame, none when the frame fits
+// into a single tile and the global pass covers it.
+func (t Tiling) tiles(cols, rows int) []image.Rectangle {
+	if t.Size <= 0 || (cols <= t.Size && rows <= t.Size) {
+		return nil
+	}
+	var tiles []image.Rectangle
+	for _, y := range spans(rows, t.Size, t.Overlap) {
+		for _, x := range spans(cols, t.Size, t.Overlap) {
+			r := image.Rect(x, y, x+t.Size, y+t.Size)
+			tiles = append(tiles, r.Intersect(image.Rect(0, 0, cols, rows)))
+		}
+	}
+	return tiles
+}
+
+// spans returns the start of each tile along a side of length pixels. The
+// last tile ends at the edge, so it may overlap its neighbor by more.
+func spans(length, size int, overlap float64) []int {
+	if length <= size {
+		return []int{0}
+	}
+	step := int(float64(size) * (1 - overlap))
+	if step < 1 {
+		step = 1
+	}
+	var starts []int
+	for x := 0; ; x += step {
+		if x+size >= length {
+			return append(starts, length-size)
+		}
+		starts = append(starts, x)
+	}
+}
@@ -0,0 +1,83 @@
+package facedetect
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		length, size int
+		overlap      float64
+		want         []int
+	}{
+		{300, 640, 0.25, []int{0}},
+		{1920, 640, 0.25, []int{0, 480, 960, 1280}},
+		{1080, 640, 0.25, []int{0, 440}},
+		{1280, 640, 0, []int{0, 640}},
+	}
+	for _, tt := range tests {
+		if got := spans(tt.length, tt.size, tt.overlap); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("spans(%d, %d, %v) = %v, want %v", tt.length, tt.size, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestTiles(t *testing.T) {
+	if tiles := (Tiling{Size: 640}).tiles(640, 480); tiles != nil {
+		t.Fatalf("frame fitting one tile: got tiles %v", tiles)
+	}
+	if tiles := (Tiling{}).tiles(1920, 1080); tiles != nil {
+		t.Fatalf("tiling disabled: got tiles %v", tiles)
+	}
+
+	// a frame narrower than a tile is cut into rows only
+	tiles := Tiling{Size: 640, Overlap: 0.25}.tiles(480, 1080)
+	want := []image.Rectangle{image.Rect(0, 0, 480, 640), image.Rect(0, 440, 480, 1080)}
+	if !reflect.DeepEqual(tiles, want) {
+		t.Fatalf("got %v, want %v", tiles, want)
+	}
+
+	tiles = Tiling{Size: 640, Overlap: 0.25}.tiles(1920, 1080)
+	if len(tiles) != 8 {
+		t.Fatalf("got %d tiles of a 1080p frame, want 8", len(tiles))
+	}
+	var covered image.Rectangle
+	for _, r := range tiles {
+		covered = covered.Union(r)
+	}
+	if covered != image.Rect(0, 0, 1920, 1080) {
+		t.Fatalf("tiles cover %v", covered)
+	}
+}
+
+func TestMergeTiles(t *testing.T) {
+	lm := func(x, y float64) *Attributes {
+		return &Attributes{Landmarks: []Landmark{{X: x, Y: y}}}
+	}
+	l := &Local{Post: PostProcess{NMS: NMSNone}}
+	found := [][]Face{
+		// the whole frame
+		{{Box: image.Rect(990, 10, 1030, 50), Confidence: 0.6}},
+		// a tile at (960, 0) finds the same face, stronger
+		{{Box: image.Rect(30, 10, 70, 50), Confidence: 0.9, Attributes: lm(40, 20)}},
+		// a tile at (960, 440) finds another one
+		{{Box: image.Rect(100, 100, 140, 140), Confidence: 0.8, Attributes: lm(110, 110)}},
+	}
+	origins := []image.Point{{}, {960, 0}, {960, 440}}
+
+	got := l.merge(found, origins, 1920, 1080)
+	// overlapping passes are suppressed even without NMS configured
+	want := []Face{
+		{Box: image.Rect(990, 10, 1030, 50), Confidence: 0.9, Attributes: lm(1000, 20)},
+		{Box: image.Rect(1060, 540, 1100, 580), Confidence: 0.8, Attributes: lm(1070, 550)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// the detections of the tile are left as they were
+	if found[1][0].Attributes.Landmarks[0] != (Landmark{X: 40, Y: 20}) {
+		t.Fatalf("merge moved the landmarks of the tile's face")
+	}
+}
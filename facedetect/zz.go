@@ -0,0 +1,59 @@
+package facedetect
+
+import (
+	"context"
+	"image"
+	"net/http"
+	"net/url"
+
+	"gocv.io/x/gocv"
+)
+
+type zzLocation struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type zzResponse struct {
+	FaceRet struct {
+		Faces []zzLocation `json:"faces"`
+	} `json:"face_ret"`
+}
+
+// ZZ calls the zz face detect function on the FDN gateway, which takes a
+// form body.
+type ZZ struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewZZ returns a detector posting to the zz FDN function at url.
+func NewZZ(url string) *ZZ {
+	return &ZZ{URL: url}
+}
+
+// Detect implements Detector. The zz function does not report confidences.
+func (z *ZZ) Detect(ctx context.Context, img gocv.Mat) ([]Face, error) {
+	imgBase64, err := encodeBase64(SourceZZ, img)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp zzResponse
+	payload := url.Values{"image": {imgBase64}, "image_type": {"BASE64"}}.Encode()
+	if err := post(ctx, z.Client, SourceZZ, z.URL, contentForm, payload, &resp); err != nil {
+		return nil, err
+	}
+
+	faces := make([]Face, 0, len(resp.FaceRet.Faces))
+	for _, f := range resp.FaceRet.Faces {
+		faces = append(faces, Face{
+			Box:        image.Rect(int(f.Left), int(f.Top), int(f.Width+f.Left), int(f.Height+f.Top)),
+			Confidence: 1,
+			Source:     SourceZZ,
+		})
+	}
+	return faces, nil
+}
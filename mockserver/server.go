@@ -0,0 +1,567 @@
+// Package mockserver stands in for the remote face detection services so
+// the adapters in package facedetect and everything built on them can be
+// exercised offline. It answers in the wire format of each service and finds
+// the faces with any facedetect.Detector, normally the local SSD network.
+//
+// Routes:
+//
+//	POST /oauth/2.0/token            Baidu access token
+//	POST /rest/2.0/face/v3/detect    Baidu face detect v3, JSON body
+//	POST /fdn-baidu                  FDN Baidu function, form body, {"body": ...}
+//	POST /zz                         FDN zz function, form body, {"face_ret": ...}
+//	POST /ibm                        IBM Cloud Functions action, JSON body and basic auth, {"detec_result": ...}
+//
+// Requests in another content type are refused with HTTP 415. The Baidu
+// style routes answer the attributes asked for with face_field; having no
+// model for them, the server makes up fixed values and spreads the landmarks
+// over the face box.
+package mockserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	mathrand "math/rand"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"gocv.io/x/gocv"
+)
+
+// Baidu error codes the server answers with.
+const (
+	codeTokenInvalid = 110
+	codeQPSLimit     = 18
+	codeImageInvalid = 222203
+	codeInternal     = 282000
+)
+
+// Content types of the request bodies.
+const (
+	contentJSON = "application/json"
+	contentForm = "application/x-www-form-urlencoded"
+)
+
+// maxBody bounds the request bodies the server reads.
+const maxBody = 16 << 20
+
+// Config shapes the behavior of the server.
+type Config struct {
+	Latency time.Duration // added to every detect answer
+	Jitter  time.Duration // random extra latency, up to this much
+
+	// ErrorRate is the share of detect requests failing with HTTP 500,
+	// BackendErrorRate the share answered with Baidu error_code 282000.
+	ErrorRate        float64
+	BackendErrorRate float64
+
+	// QPS limits the detect requests per second, 0 for no limit. Excess
+	// requests get Baidu error_code 18, or HTTP 429 from the zz route.
+	QPS float64
+
+	// Token is the access token the token route issues and the Baidu route
+	// demands. Empty issues "mock-access-token" and accepts any token.
+	Token string
+
+	// Username and Password are the basic auth credentials the IBM route
+	// demands. Empty Username accepts any credentials, but not none.
+	Username string
+	Password string
+}
+
+// Stats counts the detect requests the server answered.
+type Stats struct {
+	Requests int64 // all detect requests
+	Limited  int64 // rejected for the QPS limit
+	Injected int64 // failed by error injection
+	Faces    int64 // faces returned
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d requests, %d over the QPS limit, %d injected errors, %d faces",
+		s.Requests, s.Limited, s.Injected, s.Faces)
+}
+
+// Server emulates the detection services.
+type Server struct {
+	d   facedetect.Detector
+	cfg Config
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	rnd    *mathrand.Rand
+
+	stats Stats // updated atomically
+}
+
+// New returns a server detecting faces with d, which must be safe for
+// concurrent use.
+func New(d facedetect.Detector, cfg Config) *Server {
+	s := &Server{
+		d:      d,
+		cfg:    cfg,
+		mux:    http.NewServeMux(),
+		tokens: maxFloat(cfg.QPS, 1),
+		rnd:    mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+	}
+	s.mux.HandleFunc("/oauth/2.0/token", s.token)
+	s.mux.HandleFunc("/rest/2.0/face/v3/detect", s.baidu)
+	s.mux.HandleFunc("/fdn-baidu", s.fdnBaidu)
+	s.mux.HandleFunc("/zz", s.zz)
+	s.mux.HandleFunc("/ibm", s.ibm)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	s.mux.ServeHTTP(w, r)
+}
+
+// Stats returns the counters so far.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Requests: atomic.LoadInt64(&s.stats.Requests),
+		Limited:  atomic.LoadInt64(&s.stats.Limited),
+		Injected: atomic.LoadInt64(&s.stats.Injected),
+		Faces:    atomic.LoadInt64(&s.stats.Faces),
+	}
+}
+
+func (s *Server) accessToken() string {
+	if s.cfg.Token != "" {
+		return s.cfg.Token
+	}
+	return "mock-access-token"
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "unknown client id",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": s.accessToken(),
+		"expires_in":   30 * 24 * 3600,
+	})
+}
+
+// outcome is what happened to a detect request before its answer is sent.
+type outcome int
+
+const (
+	detected outcome = iota
+	limited
+	failStatus
+	failBackend
+	badImage
+)
+
+// detect decodes the image of a request, applies the configured latency,
+// limits and errors and runs the detector. maxFaces of 0 returns all faces.
+func (s *Server) detect(ctx context.Context, image, imageType string, maxFaces int) ([]facedetect.Face, outcome) {
+	atomic.AddInt64(&s.stats.Requests, 1)
+	if !s.allow() {
+		atomic.AddInt64(&s.stats.Limited, 1)
+		return nil, limited
+	}
+	s.delay(ctx)
+
+	s.mu.Lock()
+	p := s.rnd.Float64()
+	s.mu.Unlock()
+	switch {
+	case p < s.cfg.ErrorRate:
+		atomic.AddInt64(&s.stats.Injected, 1)
+		return nil, failStatus
+	case p < s.cfg.ErrorRate+s.cfg.BackendErrorRate:
+		atomic.AddInt64(&s.stats.Injected, 1)
+		return nil, failBackend
+	}
+
+	if imageType != "BASE64" {
+		return nil, badImage
+	}
+	buf, err := base64.StdEncoding.DecodeString(image)
+	if err != nil {
+		return nil, badImage
+	}
+	img, err := gocv.IMDecode(buf, gocv.IMReadColor)
+	if err != nil {
+		return nil, badImage
+	}
+	defer img.Close()
+	if img.Empty() {
+		return nil, badImage
+	}
+
+	faces, err := s.d.Detect(ctx, img)
+	if err != nil {
+		log.Printf("[ERR] mock detect: %v", err)
+		return nil, failBackend
+	}
+	// like Baidu, return the largest faces first
+	sort.SliceStable(faces, func(i, j int) bool {
+		a, b := faces[i].Box.Size(), faces[j].Box.Size()
+		return a.X*a.Y > b.X*b.Y
+	})
+	if maxFaces > 0 && len(faces) > maxFaces {
+		faces = faces[:maxFaces]
+	}
+	atomic.AddInt64(&s.stats.Faces, int64(len(faces)))
+	return faces, detected
+}
+
+// allow takes a token from the QPS bucket.
+func (s *Server) allow() bool {
+	if s.cfg.QPS <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if !s.last.IsZero() {
+		s.tokens += now.Sub(s.last).Seconds() * s.cfg.QPS
+	}
+	s.last = now
+	if burst := maxFloat(s.cfg.QPS, 1); s.tokens > burst {
+		s.tokens = burst
+	}
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// delay sleeps for the configured latency unless ctx ends first.
+func (s *Server) delay(ctx context.Context) {
+	d := s.cfg.Latency
+	if s.cfg.Jitter > 0 {
+		s.mu.Lock()
+		d += time.Duration(s.rnd.Int63n(int64(s.cfg.Jitter)))
+		s.mu.Unlock()
+	}
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// hasContentType reports whether the body of r is of content type ct,
+// answering with HTTP 415 if not.
+func hasContentType(w http.ResponseWriter, r *http.Request, ct string) bool {
+	got, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || got != ct {
+		http.Error(w, "Content-Type must be "+ct, http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// baiduRequest is a detect request as the Baidu style routes accept it.
+type baiduRequest struct {
+	Image      string `json:"image"`
+	ImageType  string `json:"image_type"`
+	FaceField  string `json:"face_field"`
+	MaxFaceNum int    `json:"max_face_num"`
+}
+
+// readBaiduRequest reads a detect request with a body of content type ct,
+// which the caller has checked.
+func readBaiduRequest(r *http.Request, ct string) (*baiduRequest, error) {
+	var req baiduRequest
+	if ct == contentJSON {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	req.Image = r.PostForm.Get("image")
+	req.ImageType = r.PostForm.Get("image_type")
+	req.FaceField = r.PostForm.Get("face_field")
+	if n := r.PostForm.Get("max_face_num"); n != "" {
+		var err error
+		if req.MaxFaceNum, err = strconv.Atoi(n); err != nil {
+			return nil, err
+		}
+	}
+	return &req, nil
+}
+
+type baiduLocation struct {
+	Left     float64 `json:"left"`
+	Top      float64 `json:"top"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation"`
+}
+
+type baiduAngle struct {
+	Yaw   float64 `json:"yaw"`
+	Pitch float64 `json:"pitch"`
+	Roll  float64 `json:"roll"`
+}
+
+type baiduLabel struct {
+	Type        string  `json:"type"`
+	Probability float64 `json:"probability"`
+}
+
+type baiduFace struct {
+	FaceToken   string        `json:"face_token"`
+	Location    baiduLocation `json:"location"`
+	Probability float64       `json:"face_probability"`
+	Angle       baiduAngle    `json:"angle"`
+
+	// face_field attributes, sent when asked for
+	Age         float64                        `json:"age,omitempty"`
+	Beauty      float64                        `json:"beauty,omitempty"`
+	Expression  *baiduLabel                    `json:"expression,omitempty"`
+	Gender      *baiduLabel                    `json:"gender,omitempty"`
+	Glasses     *baiduLabel                    `json:"glasses,omitempty"`
+	Quality     *facedetect.Quality            `json:"quality,omitempty"`
+	Landmark72  []facedetect.Landmark          `json:"landmark72,omitempty"`
+	Landmark150 map[string]facedetect.Landmark `json:"landmark150,omitempty"`
+}
+
+// newBaiduFace returns f as Baidu answers it, with the attributes named in
+// the comma-separated fields.
+func newBaiduFace(f facedetect.Face, fields string) baiduFace {
+	bf := baiduFace{
+		FaceToken: faceToken(),
+		Location: baiduLocation{
+			Left:     float64(f.Box.Min.X),
+			Top:      float64(f.Box.Min.Y),
+			Width:    float64(f.Box.Dx()),
+			Height:   float64(f.Box.Dy()),
+			Rotation: f.Rotation,
+		},
+		Probability: f.Confidence,
+	}
+	if a := f.Attributes; a != nil && a.Angle != nil {
+		bf.Angle = baiduAngle{Yaw: a.Angle.Yaw, Pitch: a.Angle.Pitch, Roll: a.Angle.Roll}
+	} else {
+		bf.Angle.Roll = f.Rotation
+	}
+	for _, field := range strings.Split(fields, ",") {
+		switch strings.TrimSpace(field) {
+		case "age":
+			bf.Age = 30
+		case "beauty":
+			bf.Beauty = 50
+		case "expression":
+			bf.Expression = &baiduLabel{Type: "none", Probability: 1}
+		case "gender":
+			bf.Gender = &baiduLabel{Type: "male", Probability: 1}
+		case "glasses":
+			bf.Glasses = &baiduLabel{Type: "none", Probability: 1}
+		case "quality":
+			bf.Quality = &facedetect.Quality{Illumination: 128, Completeness: 1}
+		case "landmark":
+			bf.Landmark72 = ellipse(f, 72)
+		case "landmark150":
+			bf.Landmark150 = make(map[string]facedetect.Landmark, 150)
+			for i, lm := range ellipse(f, 150) {
+				bf.Landmark150[fmt.Sprintf("point_%03d", i)] = lm
+			}
+		}
+	}
+	return bf
+}
+
+// ellipse returns n landmarks evenly spaced on the ellipse inscribed in the
+// box of f.
+func ellipse(f facedetect.Face, n int) []facedetect.Landmark {
+	cx := float64(f.Box.Min.X+f.Box.Max.X) / 2
+	cy := float64(f.Box.Min.Y+f.Box.Max.Y) / 2
+	rx, ry := float64(f.Box.Dx())/2, float64(f.Box.Dy())/2
+	pts := make([]facedetect.Landmark, n)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		pts[i] = facedetect.Landmark{X: cx + rx*math.Cos(a), Y: cy + ry*math.Sin(a)}
+	}
+	return pts
+}
+
+type baiduResult struct {
+	FaceNum  int         `json:"face_num"`
+	FaceList []baiduFace `json:"face_list"`
+}
+
+type baiduResponse struct {
+	ErrorCode int          `json:"error_code"`
+	ErrorMsg  string       `json:"error_msg"`
+	LogID     int64        `json:"log_id"`
+	Timestamp int64        `json:"timestamp"`
+	Cached    int          `json:"cached"`
+	Result    *baiduResult `json:"result"`
+}
+
+// baiduAnswer runs a Baidu style request with a body of content type ct and
+// returns the HTTP status and the Baidu body.
+func (s *Server) baiduAnswer(r *http.Request, ct string) (int, *baiduResponse) {
+	resp := &baiduResponse{ErrorMsg: "SUCCESS", LogID: mathrand.Int63(), Timestamp: time.Now().Unix()}
+	req, err := readBaiduRequest(r, ct)
+	if err != nil {
+		resp.ErrorCode, resp.ErrorMsg = 100, "Invalid parameter"
+		return http.StatusOK, resp
+	}
+	maxFaces := req.MaxFaceNum
+	if maxFaces == 0 {
+		maxFaces = 1
+	}
+
+	faces, out := s.detect(r.Context(), req.Image, req.ImageType, maxFaces)
+	switch out {
+	case limited:
+		resp.ErrorCode, resp.ErrorMsg = codeQPSLimit, "Open api qps request limit reached"
+	case failStatus:
+		return http.StatusInternalServerError, nil
+	case failBackend:
+		resp.ErrorCode, resp.ErrorMsg = codeInternal, "internal error"
+	case badImage:
+		resp.ErrorCode, resp.ErrorMsg = codeImageInvalid, "image check fail"
+	default:
+		resp.Result = &baiduResult{FaceNum: len(faces), FaceList: make([]baiduFace, 0, len(faces))}
+		for _, f := range faces {
+			resp.Result.FaceList = append(resp.Result.FaceList, newBaiduFace(f, req.FaceField))
+		}
+	}
+	return http.StatusOK, resp
+}
+
+func (s *Server) baidu(w http.ResponseWriter, r *http.Request) {
+	if token := r.URL.Query().Get("access_token"); token == "" || (s.cfg.Token != "" && token != s.cfg.Token) {
+		writeJSON(w, http.StatusOK, &baiduResponse{ErrorCode: codeTokenInvalid, ErrorMsg: "Access token invalid or no longer valid"})
+		return
+	}
+	s.answerBaidu(w, r, contentJSON, "")
+}
+
+func (s *Server) fdnBaidu(w http.ResponseWriter, r *http.Request) {
+	s.answerBaidu(w, r, contentForm, "body")
+}
+
+func (s *Server) ibm(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || (s.cfg.Username != "" && (user != s.cfg.Username || pass != s.cfg.Password)) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "The supplied authentication is invalid"})
+		return
+	}
+	s.answerBaidu(w, r, contentJSON, "detec_result")
+}
+
+// answerBaidu answers a Baidu style request with a body of content type ct,
+// with the Baidu body wrapped in an object under envelope unless that is
+// empty.
+func (s *Server) answerBaidu(w http.ResponseWriter, r *http.Request, ct, envelope string) {
+	if !hasContentType(w, r, ct) {
+		return
+	}
+	status, resp := s.baiduAnswer(r, ct)
+	switch {
+	case resp == nil:
+		writeJSON(w, status, nil)
+	case envelope == "":
+		writeJSON(w, status, resp)
+	default:
+		writeJSON(w, status, map[string]interface{}{envelope: resp})
+	}
+}
+
+type zzFace struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+func (s *Server) zz(w http.ResponseWriter, r *http.Request) {
+	if !hasContentType(w, r, contentForm) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	faces, out := s.detect(r.Context(), r.PostForm.Get("image"), r.PostForm.Get("image_type"), 0)
+	switch out {
+	case limited:
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	case failStatus, failBackend:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	case badImage:
+		http.Error(w, "image check fail", http.StatusBadRequest)
+		return
+	}
+
+	list := make([]zzFace, 0, len(faces))
+	for _, f := range faces {
+		list = append(list, zzFace{
+			Left:   float64(f.Box.Min.X),
+			Top:    float64(f.Box.Min.Y),
+			Width:  float64(f.Box.Dx()),
+			Height: float64(f.Box.Dy()),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"face_ret": map[string]interface{}{"faces": list},
+	})
+}
+
+// writeJSON answers with v, or with an empty body when v is nil.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if v == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", contentJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// faceToken returns a random token in the format of Baidu's face_token.
+func faceToken() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
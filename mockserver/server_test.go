@@ -0,0 +1,112 @@
+package mockserver
+
+import (
+	"context"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"gocv.io/x/gocv"
+)
+
+// oneFace finds the same face in every image.
+type oneFace struct{}
+
+func (oneFace) Detect(ctx context.Context, img gocv.Mat) ([]facedetect.Face, error) {
+	return []facedetect.Face{{Box: image.Rect(10, 20, 50, 80), Confidence: 0.9}}, nil
+}
+
+func newServer(t *testing.T) (*Server, *httptest.Server) {
+	s := New(oneFace{}, Config{Token: "24.token", Username: "key", Password: "secret"})
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+// params ask for attributes of every kind.
+var params = facedetect.BaiduParams{FaceField: "age,gender,quality,landmark150"}
+
+func TestServerAdapters(t *testing.T) {
+	_, srv := newServer(t)
+	tests := []struct {
+		name  string
+		d     facedetect.Detector
+		attrs bool
+	}{
+		{"baidu", &facedetect.Baidu{URL: srv.URL + "/rest/2.0/face/v3/detect?access_token=24.token", Params: params}, true},
+		{"fdn-baidu", &facedetect.FDNBaidu{URL: srv.URL + "/fdn-baidu", Params: params}, true},
+		{"zz", facedetect.NewZZ(srv.URL + "/zz"), false},
+		{"ibm", &facedetect.IBM{URL: srv.URL + "/ibm", Username: "key", Password: "secret", Params: params}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := gocv.NewMatWithSize(2, 2, gocv.MatTypeCV8UC3)
+			defer img.Close()
+			faces, err := tt.d.Detect(context.Background(), img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(faces) != 1 || faces[0].Box != image.Rect(10, 20, 50, 80) {
+				t.Fatalf("got %+v, want the face of the detector", faces)
+			}
+			if !tt.attrs {
+				return
+			}
+			a := faces[0].Attributes
+			if a == nil || a.Age == 0 || a.Gender == "" || a.Quality == nil || len(a.Landmarks) != 150 {
+				t.Fatalf("got attributes %+v, want those of face_field", a)
+			}
+			if a.Expression != "" || a.Glasses != "" {
+				t.Fatalf("got attributes %+v not asked for", a)
+			}
+			for _, lm := range a.Landmarks {
+				if !lm.Pt().In(image.Rect(10, 20, 51, 81)) {
+					t.Fatalf("landmark %+v outside the face", lm)
+				}
+			}
+		})
+	}
+}
+
+func TestServerRefuses(t *testing.T) {
+	s, srv := newServer(t)
+	tests := []struct {
+		name, path, contentType string
+		user, pass              string
+		status                  int
+	}{
+		{"baidu form", "/rest/2.0/face/v3/detect?access_token=24.token", contentForm, "", "", http.StatusUnsupportedMediaType},
+		{"fdn-baidu JSON", "/fdn-baidu", contentJSON, "", "", http.StatusUnsupportedMediaType},
+		{"zz JSON", "/zz", contentJSON, "", "", http.StatusUnsupportedMediaType},
+		{"ibm form", "/ibm", contentForm, "key", "secret", http.StatusUnsupportedMediaType},
+		{"ibm no auth", "/ibm", contentJSON, "", "", http.StatusUnauthorized},
+		{"ibm wrong password", "/ibm", contentJSON, "key", "guess", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", srv.URL+tt.path, strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+	// refused requests never reach the detector
+	if got := s.Stats().Requests; got != 0 {
+		t.Fatalf("counted %d detect requests", got)
+	}
+}
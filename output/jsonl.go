@@ -0,0 +1,127 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+)
+
+// Box is a detected face in a Record, in pixels of the processed frame. A
+// tilted face is the upright box turned clockwise by Rotation around its
+// top left corner, Bounds encloses it.
+type Box struct {
+	Left       int     `json:"left"`
+	Top        int     `json:"top"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Confidence float64 `json:"confidence"`
+	Rotation   float64 `json:"rotation"`
+	Bounds     *Rect   `json:"bounds,omitempty"` // set when Rotation is not 0
+	Label      string  `json:"label,omitempty"`
+
+	Attributes *facedetect.Attributes `json:"attributes,omitempty"`
+}
+
+// Rect is an axis-aligned rectangle.
+type Rect struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Record is the JSON line written for every processed frame.
+type Record struct {
+	Frame     int       `json:"frame"`         // index of the frame in the run
+	Seq       uint64    `json:"seq,omitempty"` // sequence number of a live frame
+	Time      time.Time `json:"time"`          // capture time
+	Source    string    `json:"source"`
+	Backend   string    `json:"backend"`
+	LatencyMS float64   `json:"latency_ms"`
+	Faces     []Box     `json:"faces"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// NewRecord builds the record of one detect call.
+func NewRecord(frame int, t time.Time, source, backend string, latency time.Duration, faces []facedetect.Face, err error) *Record {
+	r := &Record{
+		Frame:     frame,
+		Time:      t,
+		Source:    source,
+		Backend:   backend,
+		LatencyMS: float64(latency) / float64(time.Millisecond),
+		Faces:     make([]Box, 0, len(faces)),
+	}
+	for _, f := range faces {
+		b := Box{
+			Left:       f.Box.Min.X,
+			Top:        f.Box.Min.Y,
+			Width:      f.Box.Dx(),
+			Height:     f.Box.Dy(),
+			Confidence: f.Confidence,
+			Rotation:   f.Rotation,
+			Label:      f.Label,
+			Attributes: f.Attributes,
+		}
+		if f.Rotation != 0 {
+			bounds := f.Bounds()
+			b.Bounds = &Rect{Left: bounds.Min.X, Top: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()}
+		}
+		r.Faces = append(r.Faces, b)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// JSONL writes records as JSON Lines. Every record is written through as
+// soon as it is complete, so consumers can follow the output while the run
+// is going on. A JSONL is safe for concurrent use.
+type JSONL struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// NewJSONL writes records to w.
+func NewJSONL(w io.Writer) *JSONL {
+	return &JSONL{enc: json.NewEncoder(w)}
+}
+
+// CreateJSONL creates the file at path for the records; "-" is stdout.
+func CreateJSONL(path string) (*JSONL, error) {
+	if path == "-" {
+		return NewJSONL(os.Stdout), nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	j := NewJSONL(f)
+	j.c = f
+	return j, nil
+}
+
+// Write appends r as one line.
+func (j *JSONL) Write(r *Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.enc.Encode(r)
+}
+
+// Close closes the underlying file, if CreateJSONL opened one.
+func (j *JSONL) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.c == nil {
+		return nil
+	}
+	err := j.c.Close()
+	j.c = nil
+	return err
+}
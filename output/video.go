@@ -0,0 +1,225 @@
+// Package output writes the results of a capture run: annotated video files
+// and structured detection records.
+package output
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// measureFrames is how many frames are buffered to measure the frame rate
+// when none is forced.
+const measureFrames = 10
+
+// VideoConfig describes an annotated video output.
+type VideoConfig struct {
+	Path  string  // output file; the extension picks the container
+	Codec string  // FourCC such as MJPG or mp4v, empty to choose from the extension
+	FPS   float64 // frame rate written to the file, 0 to measure it from the capture times
+
+	// A new segment file is started when the current one is older than
+	// SegmentDuration or larger than SegmentSize bytes; zero disables
+	// either limit. Segments are named <name>-000<ext>, <name>-001<ext>, ...
+	SegmentDuration time.Duration
+	SegmentSize     int64
+}
+
+// segmented reports whether rotation is enabled.
+func (c *VideoConfig) segmented() bool {
+	return c.SegmentDuration > 0 || c.SegmentSize > 0
+}
+
+// codec returns the FourCC to use.
+func (c *VideoConfig) codec() string {
+	if c.Codec != "" {
+		return c.Codec
+	}
+	switch strings.ToLower(filepath.Ext(c.Path)) {
+	case ".mp4", ".m4v", ".mov":
+		return "mp4v"
+	}
+	return "MJPG"
+}
+
+// bufferedFrame is held while the frame rate is being measured.
+type bufferedFrame struct {
+	img gocv.Mat
+	t   time.Time
+}
+
+// Video writes annotated frames into one or more video files. It is not safe
+// for concurrent use.
+type Video struct {
+	cfg  VideoConfig
+	size image.Point // frame size of the files, fixed by the first frame
+	fps  float64
+
+	writer   *gocv.VideoWriter
+	file     string
+	segment  int
+	segStart time.Time
+	frames   int
+
+	pending []bufferedFrame
+	resized gocv.Mat
+}
+
+// NewVideo prepares a video output. The file is created with the first frame,
+// or after a few frames when the rate has to be measured.
+func NewVideo(cfg VideoConfig) (*Video, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("video output needs a file name")
+	}
+	if cfg.FPS < 0 {
+		return nil, fmt.Errorf("bad video frame rate %v", cfg.FPS)
+	}
+	if len(cfg.codec()) != 4 {
+		return nil, fmt.Errorf("bad video codec %q, want a FourCC", cfg.codec())
+	}
+	if dir := filepath.Dir(cfg.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Video{cfg: cfg, fps: cfg.FPS, resized: gocv.NewMat()}, nil
+}
+
+// Write appends img, captured at t, to the video. Frames of a different size
+// than the first one are scaled to fit.
+func (v *Video) Write(img gocv.Mat, t time.Time) error {
+	if v.size == (image.Point{}) {
+		v.size = image.Pt(img.Cols(), img.Rows())
+	}
+
+	if v.fps == 0 {
+		v.pending = append(v.pending, bufferedFrame{img: img.Clone(), t: t})
+		if len(v.pending) < measureFrames {
+			return nil
+		}
+		return v.flushPending()
+	}
+	return v.write(img, t)
+}
+
+// flushPending fixes the frame rate from the buffered frames and writes them.
+func (v *Video) flushPending() error {
+	v.fps = measureFPS(v.pending)
+
+	var err error
+	for _, f := range v.pending {
+		if err == nil {
+			err = v.write(f.img, f.t)
+		}
+		f.img.Close()
+	}
+	v.pending = nil
+	return err
+}
+
+// measureFPS derives the frame rate from capture timestamps, falling back to
+// 25 when the frames carry no usable timing.
+func measureFPS(frames []bufferedFrame) float64 {
+	if len(frames) > 1 {
+		span := frames[len(frames)-1].t.Sub(frames[0].t)
+		if span > 0 {
+			return float64(len(frames)-1) / span.Seconds()
+		}
+	}
+	return 25
+}
+
+func (v *Video) write(img gocv.Mat, t time.Time) error {
+	if v.writer != nil && v.rotate(t) {
+		if err := v.closeWriter(); err != nil {
+			return err
+		}
+	}
+	if v.writer == nil {
+		if err := v.open(t); err != nil {
+			return err
+		}
+	}
+
+	if img.Cols() != v.size.X || img.Rows() != v.size.Y {
+		gocv.Resize(img, &v.resized, v.size, 0, 0, gocv.InterpolationLinear)
+		img = v.resized
+	}
+	if err := v.writer.Write(img); err != nil {
+		return fmt.Errorf("write %v: %v", v.file, err)
+	}
+	v.frames++
+	return nil
+}
+
+// rotate reports whether the current segment is full.
+func (v *Video) rotate(t time.Time) bool {
+	if v.cfg.SegmentDuration > 0 && t.Sub(v.segStart) >= v.cfg.SegmentDuration {
+		return true
+	}
+	if v.cfg.SegmentSize > 0 {
+		if fi, err := os.Stat(v.file); err == nil && fi.Size() >= v.cfg.SegmentSize {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Video) open(t time.Time) error {
+	v.file = v.cfg.Path
+	if v.cfg.segmented() {
+		ext := filepath.Ext(v.cfg.Path)
+		v.file = fmt.Sprintf("%s-%03d%s", strings.TrimSuffix(v.cfg.Path, ext), v.segment, ext)
+		v.segment++
+	}
+
+	writer, err := gocv.VideoWriterFile(v.file, v.cfg.codec(), v.fps, v.size.X, v.size.Y, true)
+	if err != nil {
+		return fmt.Errorf("error opening video writer device: %v: %v", v.file, err)
+	}
+	if !writer.IsOpened() {
+		writer.Close()
+		return fmt.Errorf("error opening video writer device: %v: codec %s not supported", v.file, v.cfg.codec())
+	}
+	v.writer = writer
+	v.segStart = t
+	return nil
+}
+
+func (v *Video) closeWriter() error {
+	err := v.writer.Close()
+	v.writer = nil
+	return err
+}
+
+// Frames returns the number of frames written so far.
+func (v *Video) Frames() int {
+	return v.frames
+}
+
+// FPS returns the frame rate of the files, the configured one or the one
+// measured from the first frames; 0 while it is still being measured.
+func (v *Video) FPS() float64 {
+	return v.fps
+}
+
+// Close writes any frames still held for rate measurement and finishes the
+// current file.
+func (v *Video) Close() error {
+	var err error
+	if len(v.pending) > 0 {
+		err = v.flushPending()
+	}
+	if v.writer != nil {
+		if cerr := v.closeWriter(); err == nil {
+			err = cerr
+		}
+	}
+	v.resized.Close()
+	return err
+}
@@ -0,0 +1,205 @@
+// Package pipeline hides the latency of remote detectors by keeping several
+// detect requests in flight. With a round trip of R and K requests in flight
+// throughput approaches K/R frames per second instead of 1/R, while every
+// frame still takes R to come back.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"gocv.io/x/gocv"
+)
+
+// Frame is a frame submitted for detection.
+type Frame struct {
+	Index int       // caller's frame number
+	Seq   uint64    // caller's sequence number, e.g. of a live source
+	Time  time.Time // capture time
+	Mat   gocv.Mat
+}
+
+// Result is the outcome of detecting a Frame. The receiver owns Mat and must
+// close it.
+type Result struct {
+	Frame
+	Order   uint64 // submission order, from 0
+	Faces   []facedetect.Face
+	Err     error
+	Latency time.Duration // duration of the detect call
+}
+
+// Config configures a Pipeline.
+type Config struct {
+	Depth     int  // requests in flight, at least 1
+	Unordered bool // emit results as they complete rather than in submission order
+}
+
+// Pipeline runs detect calls concurrently and hands back their results.
+//
+// A single producer calls Reserve and then Submit for every frame, and Close
+// after the last one. A single consumer ranges over Results until it is
+// closed. Reserve blocks while Depth results are outstanding, so a slow
+// consumer bounds memory as well.
+type Pipeline struct {
+	ctx   context.Context
+	d     facedetect.Detector
+	cfg   Config
+	slots chan struct{}
+	done  chan Result
+	out   chan Result
+	calls sync.WaitGroup
+	order uint64 // next submission, only used by the producer
+
+	mu        sync.Mutex
+	started   time.Time
+	finished  time.Time
+	completed int
+	latency   time.Duration
+}
+
+// New starts a pipeline detecting with d. Cancelling ctx aborts the calls in
+// flight.
+func New(ctx context.Context, d facedetect.Detector, cfg Config) *Pipeline {
+	if cfg.Depth < 1 {
+		cfg.Depth = 1
+	}
+	p := &Pipeline{
+		ctx:   ctx,
+		d:     d,
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.Depth),
+		done:  make(chan Result, cfg.Depth),
+		out:   make(chan Result),
+	}
+	go p.collect()
+	return p
+}
+
+// Reserve waits for a free request slot. Every successful Reserve must be
+// followed by Submit, or by Release if there is no frame after all.
+// Reserving before reading the frame keeps live frames fresh.
+func (p *Pipeline) Reserve(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release gives back a reserved slot without submitting.
+func (p *Pipeline) Release() {
+	<-p.slots
+}
+
+// Submit starts detecting f in a reserved slot. The pipeline owns f.Mat
+// until it is handed back in a Result.
+func (p *Pipeline) Submit(f Frame) {
+	order := p.order
+	p.order++
+
+	p.mu.Lock()
+	if p.started.IsZero() {
+		p.started = time.Now()
+	}
+	p.mu.Unlock()
+
+	p.calls.Add(1)
+	go func() {
+		defer p.calls.Done()
+		start := time.Now()
+		faces, err := p.d.Detect(p.ctx, f.Mat)
+		p.done <- Result{Frame: f, Order: order, Faces: faces, Err: err, Latency: time.Since(start)}
+	}()
+}
+
+// Close tells the pipeline that no more frames follow. Results is closed once
+// the outstanding ones are delivered.
+func (p *Pipeline) Close() {
+	go func() {
+		p.calls.Wait()
+		close(p.done)
+	}()
+}
+
+// Results delivers the results, in submission order unless the pipeline is
+// unordered.
+func (p *Pipeline) Results() <-chan Result {
+	return p.out
+}
+
+// collect reorders the completed calls if needed and emits them.
+func (p *Pipeline) collect() {
+	defer close(p.out)
+
+	pending := make(map[uint64]Result)
+	var next uint64
+	for r := range p.done {
+		if p.cfg.Unordered {
+			p.emit(r)
+			continue
+		}
+		pending[r.Order] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			p.emit(r)
+			next++
+		}
+	}
+}
+
+func (p *Pipeline) emit(r Result) {
+	p.mu.Lock()
+	p.completed++
+	p.latency += r.Latency
+	p.finished = time.Now()
+	p.mu.Unlock()
+
+	p.out <- r
+	<-p.slots
+}
+
+// Stats describes the throughput a pipeline achieved.
+type Stats struct {
+	Depth      int
+	Completed  int
+	Elapsed    time.Duration // first submission to last result
+	AvgLatency time.Duration
+}
+
+// FPS returns the effective frame rate.
+func (s Stats) FPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Completed) / s.Elapsed.Seconds()
+}
+
+func (s Stats) String() string {
+	sequential := 0.0
+	if s.AvgLatency > 0 {
+		sequential = 1 / s.AvgLatency.Seconds()
+	}
+	return fmt.Sprintf("depth %d: %d frames, %.2f frames/s effective, avg latency %s (%.2f frames/s one at a time)",
+		s.Depth, s.Completed, s.FPS(), s.AvgLatency.Round(time.Millisecond), sequential)
+}
+
+// Stats returns the current statistics.
+func (p *Pipeline) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := Stats{Depth: p.cfg.Depth, Completed: p.completed}
+	if p.completed > 0 {
+		s.Elapsed = p.finished.Sub(p.started)
+		s.AvgLatency = p.latency / time.Duration(p.completed)
+	}
+	return s
+}
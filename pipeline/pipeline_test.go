@@ -0,0 +1,235 @@
+package pipeline
+
+import (
+	"context"
+	"image"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kkxu52452/videoCapAndProccess/facedetect"
+	"gocv.io/x/gocv"
+)
+
+// fakeDetector answers after a random latency of max/2 to max with one face as
+// wide as the frame has rows, and records how many calls overlapped.
+type fakeDetector struct {
+	max  time.Duration
+	slow int // frames with this many rows take 10 times max, 0 for none
+
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	inFlight int
+	peak     int
+}
+
+func newFakeDetector(max time.Duration) *fakeDetector {
+	return &fakeDetector{max: max, rnd: rand.New(rand.NewSource(1))}
+}
+
+func (d *fakeDetector) Detect(ctx context.Context, img gocv.Mat) ([]facedetect.Face, error) {
+	rows := img.Rows()
+	d.mu.Lock()
+	d.inFlight++
+	if d.inFlight > d.peak {
+		d.peak = d.inFlight
+	}
+	latency := d.max/2 + time.Duration(d.rnd.Int63n(int64(d.max/2)))
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.inFlight--
+		d.mu.Unlock()
+	}()
+	if rows == d.slow {
+		latency = 10 * d.max
+	}
+
+	t := time.NewTimer(latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return []facedetect.Face{{Box: image.Rect(0, 0, rows, 1), Confidence: 1}}, nil
+}
+
+// feed submits n frames from a producer goroutine and closes p. Frame i has
+// i+1 rows, so the face of its result tells which frame was detected.
+func feed(t *testing.T, p *Pipeline, n int) {
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := p.Reserve(context.Background()); err != nil {
+				t.Error(err)
+				break
+			}
+			p.Submit(Frame{Index: i, Seq: uint64(100 + i), Time: time.Now(), Mat: gocv.NewMatWithSize(i+1, 1, gocv.MatTypeCV8UC3)})
+		}
+		p.Close()
+	}()
+}
+
+// collectAll reads every result of p and checks that it belongs to its frame.
+func collectAll(t *testing.T, p *Pipeline) []Result {
+	var results []Result
+	for r := range p.Results() {
+		r.Mat.Close()
+		if r.Err != nil {
+			t.Fatalf("frame %d: %v", r.Index, r.Err)
+		}
+		if uint64(r.Index) != r.Order || r.Seq != uint64(100+r.Index) {
+			t.Fatalf("result of order %d holds frame %d, seq %d", r.Order, r.Index, r.Seq)
+		}
+		if len(r.Faces) != 1 || r.Faces[0].Box.Dx() != r.Index+1 {
+			t.Fatalf("frame %d got the faces %+v of another frame", r.Index, r.Faces)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func TestPipelineOrdered(t *testing.T) {
+	const n = 50
+	d := newFakeDetector(5 * time.Millisecond)
+	d.slow = 1 // the first frame comes back last
+	p := New(context.Background(), d, Config{Depth: 4})
+	feed(t, p, n)
+
+	results := collectAll(t, p)
+	if len(results) != n {
+		t.Fatalf("got %d results, want %d", len(results), n)
+	}
+	for i, r := range results {
+		if r.Order != uint64(i) {
+			t.Fatalf("result %d has order %d", i, r.Order)
+		}
+	}
+}
+
+func TestPipelineUnordered(t *testing.T) {
+	const n = 50
+	d := newFakeDetector(5 * time.Millisecond)
+	d.slow = 1
+	p := New(context.Background(), d, Config{Depth: 4, Unordered: true})
+	feed(t, p, n)
+
+	results := collectAll(t, p)
+	if len(results) != n {
+		t.Fatalf("got %d results, want %d", len(results), n)
+	}
+	seen := make(map[uint64]bool)
+	for _, r := range results {
+		if seen[r.Order] {
+			t.Fatalf("order %d delivered twice", r.Order)
+		}
+		seen[r.Order] = true
+	}
+	// the slow first frame does not hold back the others
+	if results[0].Order == 0 {
+		t.Fatal("the slow first frame came out first")
+	}
+}
+
+func TestPipelineDepth(t *testing.T) {
+	for _, depth := range []int{1, 3, 8} {
+		d := newFakeDetector(5 * time.Millisecond)
+		p := New(context.Background(), d, Config{Depth: depth})
+		feed(t, p, 40)
+		collectAll(t, p)
+		d.mu.Lock()
+		peak := d.peak
+		d.mu.Unlock()
+		if peak != depth {
+			t.Errorf("depth %d: %d calls in flight at most", depth, peak)
+		}
+	}
+}
+
+func TestPipelineReserveBlocks(t *testing.T) {
+	d := newFakeDetector(time.Millisecond)
+	p := New(context.Background(), d, Config{Depth: 2})
+	for i := 0; i < 2; i++ {
+		if err := p.Reserve(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		p.Submit(Frame{Index: i, Seq: uint64(100 + i), Mat: gocv.NewMatWithSize(i+1, 1, gocv.MatTypeCV8UC3)})
+	}
+
+	// the results are not read, so their slots stay taken
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.Reserve(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v with all slots taken, want context.DeadlineExceeded", err)
+	}
+
+	r := <-p.Results()
+	r.Mat.Close()
+	if err := p.Reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// a released slot can be reserved again
+	p.Release()
+	if err := p.Reserve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.Release()
+	p.Close()
+	if rest := collectAll(t, p); len(rest) != 1 || rest[0].Order != 1 {
+		t.Fatalf("got %+v, want the result of order 1", rest)
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := newFakeDetector(time.Hour)
+	p := New(ctx, d, Config{Depth: 2})
+	for i := 0; i < 2; i++ {
+		p.Reserve(context.Background())
+		p.Submit(Frame{Index: i, Mat: gocv.NewMatWithSize(1, 1, gocv.MatTypeCV8UC3)})
+	}
+	p.Close()
+	cancel()
+	var n int
+	for r := range p.Results() {
+		r.Mat.Close()
+		if r.Err != context.Canceled {
+			t.Fatalf("frame %d: got %v, want context.Canceled", r.Index, r.Err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("got %d results, want 2", n)
+	}
+}
+
+func TestPipelineStats(t *testing.T) {
+	const n = 20
+	p := New(context.Background(), newFakeDetector(5*time.Millisecond), Config{Depth: 4})
+	if s := p.Stats(); s.Completed != 0 || s.FPS() != 0 {
+		t.Fatalf("stats before any frame: %+v", s)
+	}
+	feed(t, p, n)
+	results := collectAll(t, p)
+
+	s := p.Stats()
+	if s.Depth != 4 || s.Completed != n {
+		t.Fatalf("got %+v after %d frames", s, n)
+	}
+	var total time.Duration
+	for _, r := range results {
+		total += r.Latency
+	}
+	if s.AvgLatency != total/n {
+		t.Fatalf("average latency %v, want %v", s.AvgLatency, total/n)
+	}
+	// overlapping calls beat one call at a time
+	if s.Elapsed <= 0 || s.Elapsed >= total {
+		t.Fatalf("elapsed %v for calls of %v together", s.Elapsed, total)
+	}
+	if !strings.HasPrefix(s.String(), "depth 4: 20 frames") {
+		t.Fatalf("got %q", s)
+	}
+}
@@ -0,0 +1,220 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooLarge is returned when a request or response exceeds the size limit
+// of the client.
+var ErrTooLarge = errors.New("message too large")
+
+// ClientConfig tunes the connections of a backend's client. Zero fields take
+// the value of DefaultClient.
+type ClientConfig struct {
+	// Proxy is the URL of the proxy to use, or "direct" for none. Empty
+	// uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	Proxy string `yaml:"proxy"`
+
+	MaxIdleConns          int           `yaml:"max_idle_conns"` // idle connections kept per host, at least the requests in flight
+	MaxConns              int           `yaml:"max_conns"`      // connections per host, 0 for no limit
+	IdleTimeout           time.Duration `yaml:"idle_timeout"`
+	DialTimeout           time.Duration `yaml:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"` // 0 for none
+	DisableHTTP2          bool          `yaml:"disable_http2"`
+	DisableKeepAlives     bool          `yaml:"disable_keep_alives"` // a new connection per request, for comparison
+	MaxRequestBytes       int64         `yaml:"max_request_bytes"`
+	MaxResponseBytes      int64         `yaml:"max_response_bytes"`
+}
+
+// DefaultClient holds the settings used where a ClientConfig leaves them out.
+var DefaultClient = ClientConfig{
+	MaxIdleConns:        16,
+	IdleTimeout:         90 * time.Second,
+	DialTimeout:         10 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	MaxRequestBytes:     16 << 20,
+	MaxResponseBytes:    4 << 20,
+}
+
+// withDefaults returns c with its zero fields taken from DefaultClient.
+func (c ClientConfig) withDefaults() ClientConfig {
+	d := DefaultClient
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = d.MaxIdleConns
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = d.IdleTimeout
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = d.DialTimeout
+	}
+	if c.TLSHandshakeTimeout == 0 {
+		c.TLSHandshakeTimeout = d.TLSHandshakeTimeout
+	}
+	if c.MaxRequestBytes == 0 {
+		c.MaxRequestBytes = d.MaxRequestBytes
+	}
+	if c.MaxResponseBytes == 0 {
+		c.MaxResponseBytes = d.MaxResponseBytes
+	}
+	return c
+}
+
+// NewClient returns an HTTP client configured by cfg that uses tlsConfig, if
+// not nil, for its connections. Build one client per backend and share it so
+// that connections are reused across frames.
+func NewClient(cfg ClientConfig, tlsConfig *tls.Config) (*http.Client, error) {
+	cfg = cfg.withDefaults()
+
+	proxy := http.ProxyFromEnvironment
+	switch cfg.Proxy {
+	case "":
+	case "direct":
+		proxy = nil
+	default:
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	rt := &roundTripper{maxRequest: cfg.MaxRequestBytes, maxResponse: cfg.MaxResponseBytes}
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}
+	tr := &http.Transport{
+		Proxy: proxy,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt64(&rt.dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+		MaxIdleConns:          cfg.MaxIdleConns * 4,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConns,
+		MaxConnsPerHost:       cfg.MaxConns,
+		IdleConnTimeout:       cfg.IdleTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+	}
+	if cfg.DisableHTTP2 {
+		// a non-nil empty map turns off the automatic HTTP/2 upgrade
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	rt.base = tr
+	return &http.Client{Transport: rt}, nil
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *http.Client
+)
+
+// Default returns the client shared by backends that are not given one.
+func Default() *http.Client {
+	defaultOnce.Do(func() {
+		defaultClient, _ = NewClient(ClientConfig{}, nil)
+	})
+	return defaultClient
+}
+
+// ConnStats counts the requests of a client and the connections they used.
+type ConnStats struct {
+	Requests int64 // requests that got a response
+	Dials    int64 // connections opened
+	Reused   int64 // requests sent over an existing connection
+}
+
+func (s ConnStats) String() string {
+	return fmt.Sprintf("%d requests, %d connections opened, %d requests on reused connections",
+		s.Requests, s.Dials, s.Reused)
+}
+
+// Stats returns the connection statistics of a client built by NewClient.
+func Stats(c *http.Client) (ConnStats, bool) {
+	if c == nil {
+		return ConnStats{}, false
+	}
+	rt, ok := c.Transport.(*roundTripper)
+	if !ok {
+		return ConnStats{}, false
+	}
+	return ConnStats{
+		Requests: atomic.LoadInt64(&rt.requests),
+		Dials:    atomic.LoadInt64(&rt.dials),
+		Reused:   atomic.LoadInt64(&rt.reused),
+	}, true
+}
+
+// roundTripper enforces the size limits and counts connection reuse.
+type roundTripper struct {
+	base        http.RoundTripper
+	maxRequest  int64
+	maxResponse int64
+
+	requests, dials, reused int64 // updated atomically
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.maxRequest > 0 && req.ContentLength > t.maxRequest {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("request body of %d bytes exceeds the limit of %d: %w", req.ContentLength, t.maxRequest, ErrTooLarge)
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&t.reused, 1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&t.requests, 1)
+	if t.maxResponse > 0 {
+		res.Body = &limitedBody{ReadCloser: res.Body, left: t.maxResponse, limit: t.maxResponse}
+	}
+	return res, nil
+}
+
+// limitedBody fails reads past the response size limit instead of silently
+// truncating the body.
+type limitedBody struct {
+	io.ReadCloser
+	left, limit int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		// the body may end exactly at the limit
+		var one [1]byte
+		if n, err := b.ReadCloser.Read(one[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("response body exceeds the limit of %d bytes: %w", b.limit, ErrTooLarge)
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
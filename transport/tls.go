@@ -0,0 +1,134 @@
+// Package transport builds the HTTP clients the remote detectors talk
+// through, with per-backend TLS, connection and size limit settings.
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// pinPrefix is the optional prefix of a pin, as used by HPKP and curl.
+const pinPrefix = "sha256/"
+
+// TLSConfig describes how a backend's server is authenticated and how the
+// client authenticates itself.
+type TLSConfig struct {
+	CAFile     string   `yaml:"ca_file"`     // PEM bundle trusted instead of the system roots
+	CertFile   string   `yaml:"cert_file"`   // client certificate for mutual TLS
+	KeyFile    string   `yaml:"key_file"`    // key of CertFile
+	Pins       []string `yaml:"pins"`        // accepted SPKI hashes, sha256/<base64>
+	ServerName string   `yaml:"server_name"` // expected server name, e.g. when connecting by IP
+
+	// InsecureSkipVerify disables the certificate chain, validity and host
+	// name checks. Pins, if any, are then matched against the server's own
+	// certificate only, whose key the handshake proves the server holds;
+	// the key is trusted for any name and after the certificate expired.
+	// Trusting a self-signed certificate through CAFile is stricter where
+	// the certificate allows it. Every client built this way is logged.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// IsZero reports whether c leaves everything at Go's defaults.
+func (c *TLSConfig) IsZero() bool {
+	return c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" && len(c.Pins) == 0 &&
+		c.ServerName == "" && !c.InsecureSkipVerify
+}
+
+// Build returns the tls.Config for the backend called name, which is only
+// used in messages.
+func (c *TLSConfig) Build(name string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: c.ServerName}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: CA bundle: %v", name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: CA bundle %v holds no PEM certificate", name, c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, fmt.Errorf("%s: client certificate and key must be given together", name)
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: client certificate: %v", name, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(c.Pins) > 0 {
+		pins, err := parsePins(c.Pins)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		leafOnly := c.InsecureSkipVerify
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(pins, cs, leafOnly)
+		}
+	}
+
+	if c.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		if len(c.Pins) > 0 {
+			log.Printf("[WARN] %s: TLS chain verification is disabled, trusting pinned keys only", name)
+		} else {
+			log.Printf("[WARN] %s: TLS VERIFICATION IS DISABLED, connections can be intercepted", name)
+		}
+	}
+	return cfg, nil
+}
+
+// parsePins decodes the configured SPKI hashes.
+func parsePins(pins []string) (map[[sha256.Size]byte]bool, error) {
+	set := make(map[[sha256.Size]byte]bool, len(pins))
+	for _, p := range pins {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(p, pinPrefix))
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("bad pin %q, want sha256/<base64 of a SHA-256 hash>", p)
+		}
+		var h [sha256.Size]byte
+		copy(h[:], raw)
+		set[h] = true
+	}
+	return set, nil
+}
+
+// verifyPins accepts the connection if a certificate of a verified chain
+// carries a pinned public key, or with leafOnly, for connections whose chain
+// is not verified, the server's own certificate. Other certificates the
+// server sends are not checked: anyone can send a copy of a pinned
+// certificate along with their own.
+func verifyPins(pins map[[sha256.Size]byte]bool, cs tls.ConnectionState, leafOnly bool) error {
+	if leafOnly {
+		if len(cs.PeerCertificates) > 0 && pins[sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)] {
+			return nil
+		}
+		return fmt.Errorf("the server's certificate does not match a pinned public key")
+	}
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no certificate of the verified chain matches a pinned public key")
+}
+
+// SPKIPin returns the pin of cert in the form TLSConfig.Pins expects.
+func SPKIPin(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(h[:])
+}
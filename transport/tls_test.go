@@ -0,0 +1,231 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate for 127.0.0.1 with its key.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newCert issues a certificate named name, signed by parent or self-signed
+// if parent is nil.
+func newCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if isCA {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// writeFiles writes the certificate and key of c as PEM files into a
+// temporary directory and returns their paths.
+func (c *testCert) writeFiles(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// serveTLS starts a server presenting leaf followed by extra, which the
+// server cannot prove to own. It answers with the name of the client's
+// certificate, if any.
+func serveTLS(t *testing.T, leaf *testCert, extra ...*testCert) *httptest.Server {
+	srv := newTLSServer(leaf, extra...)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTLSServer returns the server of serveTLS before it is started.
+func newTLSServer(leaf *testCert, extra ...*testCert) *httptest.Server {
+	chain := [][]byte{leaf.cert.Raw}
+	for _, c := range extra {
+		chain = append(chain, c.cert.Raw)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: chain, PrivateKey: leaf.key}}}
+	return srv
+}
+
+// get requests url through a client built from cfg.
+func get(cfg TLSConfig, url string) error {
+	tc, err := cfg.Build("test")
+	if err != nil {
+		return err
+	}
+	client, err := NewClient(ClientConfig{Proxy: "direct"}, tc)
+	if err != nil {
+		return err
+	}
+	res, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func TestTLSCustomCA(t *testing.T) {
+	ca := newCert(t, "ca", nil, true)
+	srv := serveTLS(t, newCert(t, "gateway", ca, false))
+	caFile, _ := ca.writeFiles(t)
+
+	if err := get(TLSConfig{}, srv.URL); err == nil {
+		t.Fatal("connected to a server of an unknown CA with the system roots")
+	}
+	if err := get(TLSConfig{CAFile: caFile}, srv.URL); err != nil {
+		t.Fatalf("CA file: %v", err)
+	}
+}
+
+func TestTLSPins(t *testing.T) {
+	ca := newCert(t, "ca", nil, true)
+	leaf := newCert(t, "gateway", ca, false)
+	selfSigned := newCert(t, "self-signed gateway", nil, false)
+	attacker := newCert(t, "attacker", nil, false)
+	caFile, _ := ca.writeFiles(t)
+
+	tests := []struct {
+		name     string
+		server   *httptest.Server
+		cfg      TLSConfig
+		accepted bool
+	}{
+		{"CA pinned", serveTLS(t, leaf), TLSConfig{CAFile: caFile, Pins: []string{SPKIPin(ca.cert)}}, true},
+		{"leaf pinned", serveTLS(t, leaf), TLSConfig{CAFile: caFile, Pins: []string{SPKIPin(leaf.cert)}}, true},
+		{"other key pinned", serveTLS(t, leaf), TLSConfig{CAFile: caFile, Pins: []string{SPKIPin(attacker.cert)}}, false},
+		{"pinned certificate outside the verified chain", serveTLS(t, leaf, selfSigned),
+			TLSConfig{CAFile: caFile, Pins: []string{SPKIPin(selfSigned.cert)}}, false},
+		{"self-signed pinned", serveTLS(t, selfSigned),
+			TLSConfig{Pins: []string{SPKIPin(selfSigned.cert)}, InsecureSkipVerify: true}, true},
+		{"self-signed not pinned", serveTLS(t, selfSigned),
+			TLSConfig{Pins: []string{SPKIPin(attacker.cert)}, InsecureSkipVerify: true}, false},
+		{"pinned certificate appended to another leaf", serveTLS(t, attacker, selfSigned),
+			TLSConfig{Pins: []string{SPKIPin(selfSigned.cert)}, InsecureSkipVerify: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := get(tt.cfg, tt.server.URL)
+			if tt.accepted && err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			if !tt.accepted && err == nil {
+				t.Fatal("accepted")
+			}
+		})
+	}
+}
+
+func TestTLSClientCert(t *testing.T) {
+	ca := newCert(t, "ca", nil, true)
+	caFile, _ := ca.writeFiles(t)
+	certFile, keyFile := newCert(t, "facecap", ca, false).writeFiles(t)
+
+	srv := newTLSServer(newCert(t, "gateway", ca, false))
+	srv.TLS.ClientCAs = x509.NewCertPool()
+	srv.TLS.ClientCAs.AddCert(ca.cert)
+	srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	srv.StartTLS()
+	defer srv.Close()
+
+	if err := get(TLSConfig{CAFile: caFile}, srv.URL); err == nil {
+		t.Fatal("connected without the required client certificate")
+	}
+
+	cfg := TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}
+	tc, err := cfg.Build("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, _ := NewClient(ClientConfig{Proxy: "direct"}, tc)
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("client certificate: %v", err)
+	}
+	defer res.Body.Close()
+	name, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(name); got != "facecap" {
+		t.Fatalf("server saw client %q, want facecap", got)
+	}
+}
+
+func TestTLSBuildErrors(t *testing.T) {
+	certFile, _ := newCert(t, "facecap", nil, false).writeFiles(t)
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{"missing CA file", TLSConfig{CAFile: filepath.Join(t.TempDir(), "none.pem")}},
+		{"certificate without key", TLSConfig{CertFile: certFile}},
+		{"bad pin", TLSConfig{Pins: []string{"sha256/short"}}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cfg.Build("test"); err == nil {
+			t.Errorf("%s: built", tt.name)
+		}
+	}
+}